@@ -131,6 +131,7 @@ func (a *App) startup(ctx context.Context) {
 
     statsAdapter := storage.NewStatsStorageAdapter(sqliteStorage)
     a.proxy = proxy.New(cfg, statsAdapter, deviceID)
+    a.proxy.SetCaptureDir(filepath.Join(configDir, "captures"))
 
     a.proxy.SetOnEndpointSuccess(func(endpointName string) {
         runtime.EventsEmit(ctx, "endpoint:success", endpointName)
@@ -388,8 +389,13 @@ func (a *App) SwitchToEndpoint(endpointName string) error   { return a.endpoint.
 func (a *App) TestEndpoint(index int) string                { return a.endpoint.TestEndpoint(index) }
 func (a *App) TestEndpointLight(index int) string           { return a.endpoint.TestEndpointLight(index) }
 func (a *App) TestAllEndpointsZeroCost() string             { return a.endpoint.TestAllEndpointsZeroCost() }
-func (a *App) FetchModels(apiUrl, apiKey, transformer string) string {
-    return a.endpoint.FetchModels(apiUrl, apiKey, transformer)
+// FetchModels uses the proxy and timeouts of the endpoint being edited (index -1 for a new one)
+func (a *App) FetchModels(index int, apiUrl, apiKey, transformer string) string {
+    var settings config.Endpoint
+    if endpoints := a.config.GetEndpoints(); index >= 0 && index < len(endpoints) {
+        settings = endpoints[index]
+    }
+    return a.endpoint.FetchModelsWithProxy(apiUrl, apiKey, transformer, settings)
 }
 
 // ========== Settings Bindings ==========
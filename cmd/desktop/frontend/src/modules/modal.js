@@ -249,7 +249,7 @@ export async function fetchModels() {
     fetchIcon.textContent = '⏳';
 
     try {
-        const resultStr = await window.go.main.App.FetchModels(apiUrl, apiKey, transformer);
+        const resultStr = await window.go.main.App.FetchModels(currentEditIndex, apiUrl, apiKey, transformer);
         const result = JSON.parse(resultStr);
 
         if (result.success && result.models && result.models.length > 0) {
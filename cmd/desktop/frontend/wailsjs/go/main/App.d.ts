@@ -29,7 +29,7 @@ export function FetchBroadcast(arg1:string):Promise<string>;
 
 export function FetchImageAsBase64(arg1:string):Promise<string>;
 
-export function FetchModels(arg1:string,arg2:string,arg3:string):Promise<string>;
+export function FetchModels(arg1:number,arg2:string,arg3:string,arg4:string):Promise<string>;
 
 export function GenerateMockArchives(arg1:number):Promise<string>;
 
@@ -58,8 +58,8 @@ export function FetchImageAsBase64(arg1) {
   return window['go']['main']['App']['FetchImageAsBase64'](arg1);
 }
 
-export function FetchModels(arg1, arg2, arg3) {
-  return window['go']['main']['App']['FetchModels'](arg1, arg2, arg3);
+export function FetchModels(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['FetchModels'](arg1, arg2, arg3, arg4);
 }
 
 export function GenerateMockArchives(arg1) {
@@ -7,6 +7,7 @@ import (
     "os/signal"
     "path/filepath"
     "strconv"
+    "strings"
     "syscall"
 
     "github.com/lich0821/ccNexus/internal/config"
@@ -49,6 +50,7 @@ func main() {
 
     applyEnvOverrides(cfg)
     setLogLevels(cfg.GetLogLevel())
+    logger.GetLogger().SetFormat(logger.ParseFormat(cfg.GetLogFormat()))
 
     if err := cfg.Validate(); err != nil {
         logger.Error("Invalid configuration: %v", err)
@@ -63,6 +65,7 @@ func main() {
 
     statsAdapter := storage.NewStatsStorageAdapter(sqliteStorage)
     p := proxy.New(cfg, statsAdapter, deviceID)
+    p.SetCaptureDir(filepath.Join(dataDir, "captures"))
 
     // Create HTTP mux
     mux := http.NewServeMux()
@@ -149,6 +152,88 @@ func applyEnvOverrides(cfg *config.Config) {
             logger.Warn("Invalid CCNEXUS_LOG_LEVEL value %q: %v", levelStr, err)
         }
     }
+
+    if format := os.Getenv("CCNEXUS_LOG_FORMAT"); format != "" {
+        if config.IsValidLogFormat(format) {
+            cfg.UpdateLogFormat(format)
+        } else {
+            logger.Warn("Invalid CCNEXUS_LOG_FORMAT value %q (must be text or json)", format)
+        }
+    }
+
+    applyAdminEnvOverrides(cfg)
+    applyTracingEnvOverrides(cfg)
+}
+
+// applyTracingEnvOverrides enables trace export when the standard OTLP endpoint variables are set
+func applyTracingEnvOverrides(cfg *config.Config) {
+    var override config.TracingOverride
+
+    if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+        override.Endpoint = endpoint
+    } else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+        override.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+    }
+
+    if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+        override.ServiceName = name
+    }
+
+    if override == (config.TracingOverride{}) {
+        return
+    }
+    tracing := cfg.GetTracing()
+    if override.Endpoint != "" {
+        tracing.Endpoint = override.Endpoint
+        tracing.Enabled = true
+    }
+    if override.ServiceName != "" {
+        tracing.ServiceName = override.ServiceName
+    }
+    if err := config.ValidateTracing(tracing); err != nil {
+        logger.Warn("Ignoring OpenTelemetry environment: %v", err)
+        return
+    }
+    // Kept in memory only, like the admin overrides
+    cfg.SetTracingOverride(override)
+}
+
+// applyAdminEnvOverrides takes the admin API credentials and CORS allow-list from the environment
+func applyAdminEnvOverrides(cfg *config.Config) {
+    var override config.AdminOverride
+
+    if password := os.Getenv("CCNEXUS_ADMIN_PASSWORD"); password != "" {
+        if hash, err := config.HashPassword(password); err == nil {
+            override.PasswordHash = hash
+        } else {
+            logger.Warn("Failed to hash CCNEXUS_ADMIN_PASSWORD: %v", err)
+        }
+    }
+
+    if token := os.Getenv("CCNEXUS_ADMIN_TOKEN"); token != "" {
+        override.TokenHash = config.HashToken(token)
+    }
+
+    if origins := os.Getenv("CCNEXUS_ALLOWED_ORIGINS"); origins != "" {
+        override.AllowedOrigins = []string{}
+        for _, origin := range strings.Split(origins, ",") {
+            if origin = strings.TrimSpace(origin); origin != "" {
+                override.AllowedOrigins = append(override.AllowedOrigins, strings.TrimSuffix(origin, "/"))
+            }
+        }
+        admin := cfg.GetAdmin()
+        admin.AllowedOrigins = override.AllowedOrigins
+        if err := config.ValidateAdmin(admin); err != nil {
+            logger.Warn("Ignoring CCNEXUS_ALLOWED_ORIGINS: %v", err)
+            override.AllowedOrigins = nil
+        }
+    }
+
+    // The overrides live in memory only; unsetting a variable takes effect on the next start
+    cfg.SetAdminOverride(override)
+    if !cfg.GetAdmin().AuthRequired() {
+        logger.Warn("Admin API only accepts local requests until CCNEXUS_ADMIN_PASSWORD or CCNEXUS_ADMIN_TOKEN is set")
+    }
 }
 
 func setLogLevels(level int) {
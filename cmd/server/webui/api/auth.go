@@ -0,0 +1,407 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/storage"
+)
+
+const (
+	// sessionCookie holds the admin session of the web UI
+	sessionCookie = "ccnexus_session"
+	// csrfHeader carries the session's CSRF token on state-changing requests
+	csrfHeader = "X-CSRF-Token"
+	// minPasswordLength is the shortest admin password accepted by the API
+	minPasswordLength = 8
+)
+
+// session is a logged-in web UI session
+type session struct {
+	csrfToken string
+	expiresAt time.Time
+}
+
+// sessionStore keeps the admin sessions in memory; they end when the server restarts
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// newSessionStore creates an empty session store
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session)}
+}
+
+// create starts a session valid for ttl and returns its ID
+func (s *sessionStore) create(ttl time.Duration) (string, *session, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	csrf, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, sess := range s.sessions {
+		if !now.Before(sess.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	sess := &session{csrfToken: csrf, expiresAt: now.Add(ttl)}
+	s.sessions[id] = sess
+	return id, sess, nil
+}
+
+// get returns the session with the given ID, or nil if there is none or it expired
+func (s *sessionStore) get(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(sess.expiresAt) {
+		delete(s.sessions, id)
+		return nil
+	}
+	return sess
+}
+
+// remove ends a session
+func (s *sessionStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// clear ends all sessions
+func (s *sessionStore) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*session)
+}
+
+// requestSession returns the session of the request's cookie, or nil
+func (h *Handler) requestSession(r *http.Request) (string, *session) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return "", nil
+	}
+	if sess := h.sessions.get(cookie.Value); sess != nil {
+		return cookie.Value, sess
+	}
+	return "", nil
+}
+
+// validAdminToken checks the request's bearer token against the admin API token
+func validAdminToken(r *http.Request, admin config.AdminConfig) bool {
+	if admin.TokenHash == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return false
+	}
+	hash := config.HashToken(strings.TrimSpace(auth[7:]))
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(admin.TokenHash)) == 1
+}
+
+// isLocalRequest checks if a request comes straight from this machine. Requests relayed by a
+// reverse proxy are not local, even when the proxy itself runs here.
+func isLocalRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" || r.Header.Get("X-Real-Ip") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isSafeMethod checks if a request method does not change state
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// originAllowed checks if a browser request comes from the admin UI itself or an allowed origin.
+// Requests without an Origin header do not come from a cross-origin page and are allowed.
+func originAllowed(r *http.Request, admin config.AdminConfig) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	for _, allowed := range admin.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware answers CORS requests for the origins in the admin allow-list. Other
+// origins get no CORS headers, and their state-changing requests are refused.
+func (h *Handler) CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin := h.config.GetAdmin()
+		origin := r.Header.Get("Origin")
+		allowed := originAllowed(r, admin)
+
+		if origin != "" && allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+csrfHeader)
+		}
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions {
+			if !allowed {
+				WriteError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !allowed && !isSafeMethod(r.Method) {
+			logger.Warn("[API] Refused %s %s from origin %s", r.Method, r.URL.Path, origin)
+			WriteError(w, http.StatusForbidden, "Origin not allowed")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthMiddleware requires an admin session or the admin API token once a password or token
+// is configured. Until then only local requests are served, so that nobody else can set the
+// first password. Session requests that change state must also send the session's CSRF token.
+func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin := h.config.GetAdmin()
+		if isPublicAuthPath(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !admin.AuthRequired() {
+			if !isLocalRequest(r) {
+				logger.Warn("[API] Refused %s %s from %s: no admin credentials are set", r.Method, r.URL.Path, r.RemoteAddr)
+				WriteError(w, http.StatusForbidden, "The admin API only accepts local requests until an admin password or token is set")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if validAdminToken(r, admin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, sess := h.requestSession(r)
+		if sess == nil {
+			WriteError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !isSafeMethod(r.Method) &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(sess.csrfToken)) != 1 {
+			WriteError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isPublicAuthPath checks if a request may reach the API without being logged in
+func isPublicAuthPath(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/api/auth/login", "/api/auth/session":
+		return true
+	}
+	return false
+}
+
+// handleAuthSession reports whether the admin API needs a login and whether the caller has one
+func (h *Handler) handleAuthSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	admin := h.config.GetAdmin()
+	resp := map[string]interface{}{
+		"authRequired":  admin.AuthRequired(),
+		"passwordLogin": admin.PasswordHash != "",
+		"authenticated": !admin.AuthRequired() && isLocalRequest(r),
+	}
+	if _, sess := h.requestSession(r); sess != nil {
+		resp["authenticated"] = true
+		resp["csrfToken"] = sess.csrfToken
+		resp["expiresAt"] = sess.expiresAt
+	}
+	WriteSuccess(w, resp)
+}
+
+// handleAuthLogin checks the admin password and starts a session cookie
+func (h *Handler) handleAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	admin := h.config.GetAdmin()
+	if admin.PasswordHash == "" {
+		WriteError(w, http.StatusBadRequest, "Password login is not enabled")
+		return
+	}
+	if !config.CheckPassword(admin.PasswordHash, req.Password) {
+		logger.Warn("[API] Failed admin login from %s", r.RemoteAddr)
+		WriteError(w, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+
+	id, sess, err := h.sessions.create(time.Duration(admin.SessionTTL) * time.Hour)
+	if err != nil {
+		logger.Error("Failed to create admin session: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  sess.expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	logger.Info("[API] Admin logged in from %s", r.RemoteAddr)
+
+	WriteSuccess(w, map[string]interface{}{
+		"csrfToken": sess.csrfToken,
+		"expiresAt": sess.expiresAt,
+	})
+}
+
+// handleAuthLogout ends the caller's session
+func (h *Handler) handleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if id, _ := h.requestSession(r); id != "" {
+		h.sessions.remove(id)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	WriteSuccess(w, map[string]interface{}{
+		"message": "Logged out successfully",
+	})
+}
+
+// handleAuthPassword sets or changes the admin password. Changing it requires the current
+// password and ends all sessions; the first password can only be set from this machine
+// (see AuthMiddleware) or by a caller holding the admin token.
+func (h *Handler) handleAuthPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if h.config.GetAdminOverride().PasswordHash != "" {
+		WriteError(w, http.StatusConflict, "The admin password is set by CCNEXUS_ADMIN_PASSWORD")
+		return
+	}
+
+	admin := h.config.GetAdmin()
+	if admin.PasswordHash != "" && !config.CheckPassword(admin.PasswordHash, req.CurrentPassword) {
+		WriteError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		WriteError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	}
+
+	hash, err := config.HashPassword(req.NewPassword)
+	if err != nil {
+		logger.Error("Failed to hash admin password: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to set password")
+		return
+	}
+	admin.PasswordHash = hash
+	h.config.UpdateAdmin(&admin)
+
+	// Save to storage
+	adapter := storage.NewConfigStorageAdapter(h.storage)
+	if err := h.config.SaveToStorage(adapter); err != nil {
+		logger.Error("Failed to save config: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to save configuration")
+		return
+	}
+
+	h.sessions.clear()
+	logger.Info("[API] Admin password changed from %s", r.RemoteAddr)
+
+	WriteSuccess(w, map[string]interface{}{
+		"message": "Password updated successfully, please log in again",
+	})
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
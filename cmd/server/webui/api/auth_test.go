@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	const token = "admin-token"
+
+	tests := []struct {
+		name       string
+		override   config.AdminOverride
+		method     string
+		path       string
+		remoteAddr string
+		header     map[string]string
+		want       int
+	}{
+		{name: "no credentials, remote", method: http.MethodGet, path: "/api/endpoints", remoteAddr: "192.0.2.1:4000", want: http.StatusForbidden},
+		{name: "no credentials, remote password set", method: http.MethodPut, path: "/api/auth/password", remoteAddr: "192.0.2.1:4000", want: http.StatusForbidden},
+		{name: "no credentials, loopback", method: http.MethodGet, path: "/api/endpoints", remoteAddr: "127.0.0.1:4000", want: http.StatusOK},
+		{name: "no credentials, IPv6 loopback", method: http.MethodPut, path: "/api/auth/password", remoteAddr: "[::1]:4000", want: http.StatusOK},
+		{name: "no credentials, forwarded to loopback", method: http.MethodGet, path: "/api/endpoints", remoteAddr: "127.0.0.1:4000",
+			header: map[string]string{"X-Forwarded-For": "192.0.2.1"}, want: http.StatusForbidden},
+		{name: "no credentials, public session path", method: http.MethodGet, path: "/api/auth/session", remoteAddr: "192.0.2.1:4000", want: http.StatusOK},
+		{name: "token, missing", override: config.AdminOverride{TokenHash: config.HashToken(token)}, method: http.MethodGet, path: "/api/endpoints",
+			remoteAddr: "127.0.0.1:4000", want: http.StatusUnauthorized},
+		{name: "token, wrong", override: config.AdminOverride{TokenHash: config.HashToken(token)}, method: http.MethodGet, path: "/api/endpoints",
+			remoteAddr: "192.0.2.1:4000", header: map[string]string{"Authorization": "Bearer nope"}, want: http.StatusUnauthorized},
+		{name: "token, valid", override: config.AdminOverride{TokenHash: config.HashToken(token)}, method: http.MethodPut, path: "/api/auth/password",
+			remoteAddr: "192.0.2.1:4000", header: map[string]string{"Authorization": "Bearer " + token}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.SetAdminOverride(tt.override)
+			h := &Handler{config: cfg, sessions: newSessionStore()}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			h.AuthMiddleware(next).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %q)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleAuthPasswordEnvOverride(t *testing.T) {
+	hash, err := config.HashPassword("from-the-environment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.SetAdminOverride(config.AdminOverride{PasswordHash: hash})
+	h := &Handler{config: cfg, sessions: newSessionStore()}
+
+	body := `{"currentPassword":"from-the-environment","newPassword":"something-else"}`
+	r := httptest.NewRequest(http.MethodPut, "/api/auth/password", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.handleAuthPassword(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if got := cfg.GetAdmin().PasswordHash; got != hash {
+		t.Errorf("password hash changed to %q", got)
+	}
+}
+
+func TestUpdateAdminKeepsOverridesOutOfStorage(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SetAdminOverride(config.AdminOverride{
+		TokenHash:      config.HashToken("env-token"),
+		AllowedOrigins: []string{"https://env.example.com"},
+	})
+
+	admin := cfg.GetAdmin()
+	admin.SessionTTL = 12
+	cfg.UpdateAdmin(&admin)
+
+	if cfg.Admin.TokenHash != "" || cfg.Admin.AllowedOrigins != nil {
+		t.Errorf("overrides leaked into the stored settings: %+v", *cfg.Admin)
+	}
+	if cfg.Admin.SessionTTL != 12 {
+		t.Errorf("sessionTTL = %d, want 12", cfg.Admin.SessionTTL)
+	}
+	if got := cfg.GetAdmin(); got.TokenHash != config.HashToken("env-token") || len(got.AllowedOrigins) != 1 {
+		t.Errorf("overrides not applied: %+v", got)
+	}
+
+	reloaded := config.DefaultConfig()
+	reloaded.InheritOverrides(cfg)
+	if !reloaded.GetAdmin().AuthRequired() {
+		t.Error("reloaded config lost the environment token")
+	}
+}
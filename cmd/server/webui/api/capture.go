@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// handleCapture returns the capture directory, how many requests are still armed and the
+// capture files, newest first
+func (h *Handler) handleCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	recorder := h.proxy.GetCapture()
+	files, err := recorder.Files()
+	if err != nil {
+		logger.Error("Failed to list capture files: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to list capture files")
+		return
+	}
+	if files == nil {
+		files = []capture.FileInfo{}
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"dir":   recorder.Dir(),
+		"armed": recorder.Armed(),
+		"files": files,
+	})
+}
+
+// handleCaptureArm captures the next count requests whatever the capture settings; 0 disarms
+func (h *Handler) handleCaptureArm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Count < 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid count (must not be negative)")
+		return
+	}
+
+	recorder := h.proxy.GetCapture()
+	recorder.Arm(req.Count)
+	logger.Info("[CAPTURE] Capturing the next %d requests to %s", req.Count, recorder.Dir())
+
+	WriteSuccess(w, map[string]interface{}{
+		"armed": recorder.Armed(),
+	})
+}
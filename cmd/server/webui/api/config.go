@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/proxy"
 	"github.com/lich0821/ccNexus/internal/storage"
 )
 
@@ -23,16 +25,42 @@ func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
 // getConfig returns the full configuration
 func (h *Handler) getConfig(w http.ResponseWriter, r *http.Request) {
 	WriteSuccess(w, map[string]interface{}{
-		"port":     h.config.GetPort(),
-		"logLevel": h.config.GetLogLevel(),
+		"port":           h.config.GetPort(),
+		"logLevel":       h.config.GetLogLevel(),
+		"logFormat":      h.config.GetLogFormat(),
+		"loadBalancing":  h.config.GetLoadBalancing(),
+		"circuitBreaker": h.config.GetCircuitBreaker(),
+		"routing":        h.config.GetRouting(),
+		"streaming":      h.config.GetStreaming(),
+		"hedging":        h.config.GetHedging(),
+		"concurrency":    h.config.GetConcurrency(),
+		"sticky":         h.config.GetSticky(),
+		"transport":      h.config.GetTransport(),
+		"admin":          h.config.GetAdmin(),
+		"tracing":        h.config.GetTracing(),
+		"requestLog":     h.config.GetRequestLog(),
+		"capture":        h.config.GetCapture(),
 	})
 }
 
 // updateConfig updates the full configuration
 func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Port     int `json:"port"`
-		LogLevel int `json:"logLevel"`
+		Port           int                          `json:"port"`
+		LogLevel       int                          `json:"logLevel"`
+		LogFormat      string                       `json:"logFormat"`
+		LoadBalancing  string                       `json:"loadBalancing"`
+		CircuitBreaker *config.CircuitBreakerConfig `json:"circuitBreaker"`
+		Routing        *[]config.RoutingRule        `json:"routing"`
+		Streaming      *config.StreamingConfig      `json:"streaming"`
+		Hedging        *config.HedgingConfig        `json:"hedging"`
+		Concurrency    *config.ConcurrencyConfig    `json:"concurrency"`
+		Sticky         *config.StickyConfig         `json:"sticky"`
+		Transport      *config.TransportConfig      `json:"transport"`
+		Admin          *config.AdminConfig          `json:"admin"`
+		Tracing        *config.TracingConfig        `json:"tracing"`
+		RequestLog     *config.RequestLogConfig     `json:"requestLog"`
+		Capture        *config.CaptureConfig        `json:"capture"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -40,6 +68,69 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.LogFormat != "" && !config.IsValidLogFormat(req.LogFormat) {
+		WriteError(w, http.StatusBadRequest, "Invalid logFormat (must be text or json)")
+		return
+	}
+	if req.LoadBalancing != "" && !proxy.IsValidStrategy(req.LoadBalancing) {
+		WriteError(w, http.StatusBadRequest, "Invalid loadBalancing (must be failover, round_robin, weighted or least_inflight)")
+		return
+	}
+	if req.CircuitBreaker != nil && (req.CircuitBreaker.FailureThreshold < 0 || req.CircuitBreaker.CooldownSeconds < 0) {
+		WriteError(w, http.StatusBadRequest, "Invalid circuitBreaker (values must not be negative)")
+		return
+	}
+	if req.Streaming != nil && req.Streaming.FirstEventTimeout < 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid streaming (firstEventTimeout must not be negative)")
+		return
+	}
+	if req.Hedging != nil && req.Hedging.DelayMs < 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid hedging (delayMs must not be negative)")
+		return
+	}
+	if req.Concurrency != nil && (req.Concurrency.QueueSize < 0 || req.Concurrency.QueueTimeout < 0) {
+		WriteError(w, http.StatusBadRequest, "Invalid concurrency (values must not be negative)")
+		return
+	}
+	if req.Sticky != nil && (req.Sticky.TTL <= 0 || req.Sticky.MaxEntries <= 0) {
+		WriteError(w, http.StatusBadRequest, "Invalid sticky (ttl and maxEntries must be positive)")
+		return
+	}
+	if req.Transport != nil {
+		if err := config.ValidateTransport(*req.Transport); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Admin != nil {
+		if err := config.ValidateAdmin(*req.Admin); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Tracing != nil {
+		if err := config.ValidateTracing(*req.Tracing); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.RequestLog != nil && req.RequestLog.RetentionDays < 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid requestLog (retentionDays must not be negative)")
+		return
+	}
+	if req.Capture != nil {
+		if err := config.ValidateCapture(*req.Capture); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Routing != nil {
+		if err := config.ValidateRoutingRules(*req.Routing); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	// Update port if provided
 	if req.Port > 0 {
 		h.config.UpdatePort(req.Port)
@@ -50,6 +141,75 @@ func (h *Handler) updateConfig(w http.ResponseWriter, r *http.Request) {
 		h.config.UpdateLogLevel(req.LogLevel)
 	}
 
+	// Update log format if provided; it applies to the next log line
+	if req.LogFormat != "" {
+		h.config.UpdateLogFormat(req.LogFormat)
+		logger.GetLogger().SetFormat(logger.ParseFormat(req.LogFormat))
+	}
+
+	// Update load balancing strategy if provided
+	if req.LoadBalancing != "" {
+		h.config.UpdateLoadBalancing(req.LoadBalancing)
+	}
+
+	// Update circuit breaker if provided
+	if req.CircuitBreaker != nil {
+		h.config.UpdateCircuitBreaker(req.CircuitBreaker)
+	}
+
+	// Update streaming config if provided
+	if req.Streaming != nil {
+		h.config.UpdateStreaming(req.Streaming)
+	}
+
+	// Update hedging config if provided
+	if req.Hedging != nil {
+		h.config.UpdateHedging(req.Hedging)
+	}
+
+	// Update concurrency queue config if provided
+	if req.Concurrency != nil {
+		h.config.UpdateConcurrency(req.Concurrency)
+	}
+
+	// Update sticky routing config if provided
+	if req.Sticky != nil {
+		h.config.UpdateSticky(req.Sticky)
+	}
+
+	// Update transport config if provided; pooled connections pick it up on their next request
+	if req.Transport != nil {
+		h.config.UpdateTransport(req.Transport)
+	}
+
+	// Update admin session and CORS settings if provided; credentials are changed separately
+	if req.Admin != nil {
+		admin := h.config.GetAdmin()
+		admin.SessionTTL = req.Admin.SessionTTL
+		admin.AllowedOrigins = req.Admin.AllowedOrigins
+		h.config.UpdateAdmin(&admin)
+	}
+
+	// Update tracing config if provided; the proxy applies it on its next request
+	if req.Tracing != nil {
+		h.config.UpdateTracing(req.Tracing)
+	}
+
+	// Update request log config if provided; the retention applies at the next hourly prune
+	if req.RequestLog != nil {
+		h.config.UpdateRequestLog(req.RequestLog)
+	}
+
+	// Update capture config if provided; the proxy applies it on its next request
+	if req.Capture != nil {
+		h.config.UpdateCapture(req.Capture)
+	}
+
+	// Update routing rules if provided (an empty list clears them)
+	if req.Routing != nil {
+		h.config.UpdateRouting(*req.Routing)
+	}
+
 	// Save to storage
 	adapter := storage.NewConfigStorageAdapter(h.storage)
 	if err := h.config.SaveToStorage(adapter); err != nil {
@@ -2,12 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/proxy"
 	"github.com/lich0821/ccNexus/internal/storage"
 )
 
@@ -68,16 +70,67 @@ func (h *Handler) listEndpoints(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Mask API keys
+	// Mask API keys and attach runtime state
+	views := make([]endpointView, 0, len(endpoints))
 	for i := range endpoints {
-		endpoints[i].APIKey = maskAPIKey(endpoints[i].APIKey)
+		views = append(views, h.newEndpointView(endpoints[i]))
 	}
 
 	WriteSuccess(w, map[string]interface{}{
-		"endpoints": endpoints,
+		"endpoints": views,
 	})
 }
 
+// endpointView is an endpoint as returned by the API, including proxy runtime state
+type endpointView struct {
+	storage.Endpoint
+	Breaker       proxy.BreakerStatus `json:"breaker"`
+	CooldownUntil *time.Time          `json:"cooldownUntil,omitempty"`
+	InFlight      int                 `json:"inFlight"`
+	Queued        int                 `json:"queued"`
+	Keys          []keyView           `json:"keys,omitempty"`
+}
+
+// keyView is the rotation state of one API key of an endpoint, with the key masked
+type keyView struct {
+	Key     string `json:"key"`
+	Label   string `json:"label,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Primary bool   `json:"primary,omitempty"` // the endpoint's apiKey
+	proxy.KeyStatus
+}
+
+// newEndpointView attaches runtime state to a stored endpoint and masks its API keys
+func (h *Handler) newEndpointView(ep storage.Endpoint) endpointView {
+	var keys []keyView
+	if len(ep.APIKeys) > 0 {
+		keys = append(keys, keyView{
+			Key:       maskAPIKey(ep.APIKey),
+			Enabled:   true,
+			Primary:   true,
+			KeyStatus: h.proxy.GetKeyStatus(ep.Name, ep.APIKey),
+		})
+		for _, k := range ep.APIKeys {
+			keys = append(keys, keyView{
+				Key:       maskAPIKey(k.Key),
+				Label:     k.Label,
+				Enabled:   k.Enabled,
+				KeyStatus: h.proxy.GetKeyStatus(ep.Name, k.Key),
+			})
+		}
+	}
+
+	maskEndpointKeys(&ep)
+	return endpointView{
+		Endpoint:      ep,
+		Breaker:       h.proxy.GetBreakerStatus(ep.Name),
+		CooldownUntil: h.proxy.GetCooldownUntil(ep.Name),
+		InFlight:      h.proxy.GetInFlight(ep.Name),
+		Queued:        h.proxy.QueueLength(ep.Name),
+		Keys:          keys,
+	}
+}
+
 // getEndpoint returns a specific endpoint
 func (h *Handler) getEndpoint(w http.ResponseWriter, r *http.Request, name string) {
 	endpoints, err := h.storage.GetEndpoints()
@@ -89,8 +142,7 @@ func (h *Handler) getEndpoint(w http.ResponseWriter, r *http.Request, name strin
 
 	for _, ep := range endpoints {
 		if ep.Name == name {
-			ep.APIKey = maskAPIKey(ep.APIKey)
-			WriteSuccess(w, ep)
+			WriteSuccess(w, h.newEndpointView(ep))
 			return
 		}
 	}
@@ -101,13 +153,28 @@ func (h *Handler) getEndpoint(w http.ResponseWriter, r *http.Request, name strin
 // createEndpoint creates a new endpoint
 func (h *Handler) createEndpoint(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name        string `json:"name"`
-		APIUrl      string `json:"apiUrl"`
-		APIKey      string `json:"apiKey"`
-		Enabled     bool   `json:"enabled"`
-		Transformer string `json:"transformer"`
-		Model       string `json:"model"`
-		Remark      string `json:"remark"`
+		Name             string                `json:"name"`
+		APIUrl           string                `json:"apiUrl"`
+		APIKey           string                `json:"apiKey"`
+		Enabled          bool                  `json:"enabled"`
+		Transformer      string                `json:"transformer"`
+		Model            string                `json:"model"`
+		Remark           string                `json:"remark"`
+		Weight           int                   `json:"weight"`
+		Group            string                `json:"group"`
+		ModelMap         []config.ModelMapping `json:"modelMap"`
+		MaxConcurrent    int                   `json:"maxConcurrent"`
+		RPM              int                   `json:"rpm"`
+		TPM              int                   `json:"tpm"`
+		ProxyURL         string                `json:"proxyUrl"`
+		ConnectTimeout   int                   `json:"connectTimeout"`
+		FirstByteTimeout int                   `json:"firstByteTimeout"`
+		TotalTimeout     int                   `json:"totalTimeout"`
+		AuthStyle        string                `json:"authStyle"`
+		AuthHeader       string                `json:"authHeader"`
+		Headers          []config.HeaderRule   `json:"headers"`
+		APIKeys          []config.APIKey       `json:"apiKeys"`
+		KeyStrategy      string                `json:"keyStrategy"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -127,6 +194,46 @@ func (h *Handler) createEndpoint(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusBadRequest, "model is required for non-claude transformer")
 		return
 	}
+	if req.Weight < 0 {
+		WriteError(w, http.StatusBadRequest, "weight must not be negative")
+		return
+	}
+	if err := config.ValidateModelMap(req.ModelMap); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.MaxConcurrent < 0 {
+		WriteError(w, http.StatusBadRequest, "maxConcurrent must not be negative")
+		return
+	}
+	if req.RPM < 0 || req.TPM < 0 {
+		WriteError(w, http.StatusBadRequest, "rpm and tpm must not be negative")
+		return
+	}
+	if req.ConnectTimeout < 0 || req.FirstByteTimeout < 0 || req.TotalTimeout < 0 {
+		WriteError(w, http.StatusBadRequest, "timeouts must not be negative")
+		return
+	}
+	req.ProxyURL = strings.TrimSpace(req.ProxyURL)
+	if err := config.ValidateProxyURL(req.ProxyURL); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := config.ValidateAuthStyle(req.AuthStyle, strings.TrimSpace(req.AuthHeader)); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := config.ValidateHeaderRules(req.Headers); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for i := range req.APIKeys {
+		req.APIKeys[i].Key = strings.TrimSpace(req.APIKeys[i].Key)
+	}
+	if err := config.ValidateAPIKeys(req.APIKeys, req.KeyStrategy); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Get current endpoints to determine sort order
 	endpoints, err := h.storage.GetEndpoints()
@@ -146,16 +253,31 @@ func (h *Handler) createEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	// Create new endpoint
 	endpoint := &storage.Endpoint{
-		Name:        req.Name,
-		APIUrl:      normalizeAPIUrl(req.APIUrl),
-		APIKey:      req.APIKey,
-		Enabled:     req.Enabled,
-		Transformer: req.Transformer,
-		Model:       req.Model,
-		Remark:      req.Remark,
-		SortOrder:   len(endpoints),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Name:             req.Name,
+		APIUrl:           normalizeAPIUrl(req.APIUrl),
+		APIKey:           req.APIKey,
+		Enabled:          req.Enabled,
+		Transformer:      req.Transformer,
+		Model:            req.Model,
+		Remark:           req.Remark,
+		SortOrder:        len(endpoints),
+		Weight:           req.Weight,
+		Group:            strings.TrimSpace(req.Group),
+		ModelMap:         req.ModelMap,
+		MaxConcurrent:    req.MaxConcurrent,
+		RPM:              req.RPM,
+		TPM:              req.TPM,
+		ProxyURL:         req.ProxyURL,
+		ConnectTimeout:   req.ConnectTimeout,
+		FirstByteTimeout: req.FirstByteTimeout,
+		TotalTimeout:     req.TotalTimeout,
+		AuthStyle:        req.AuthStyle,
+		AuthHeader:       strings.TrimSpace(req.AuthHeader),
+		Headers:          req.Headers,
+		APIKeys:          req.APIKeys,
+		KeyStrategy:      req.KeyStrategy,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 
 	if err := h.storage.SaveEndpoint(endpoint); err != nil {
@@ -169,20 +291,35 @@ func (h *Handler) createEndpoint(w http.ResponseWriter, r *http.Request) {
 		logger.Error("Failed to reload config: %v", err)
 	}
 
-	endpoint.APIKey = maskAPIKey(endpoint.APIKey)
+	maskEndpointKeys(endpoint)
 	WriteSuccess(w, endpoint)
 }
 
 // updateEndpoint updates an existing endpoint
 func (h *Handler) updateEndpoint(w http.ResponseWriter, r *http.Request, name string) {
 	var req struct {
-		Name        string `json:"name"`
-		APIUrl      string `json:"apiUrl"`
-		APIKey      string `json:"apiKey"`
-		Enabled     *bool  `json:"enabled"`
-		Transformer string `json:"transformer"`
-		Model       string `json:"model"`
-		Remark      string `json:"remark"`
+		Name             string                 `json:"name"`
+		APIUrl           string                 `json:"apiUrl"`
+		APIKey           string                 `json:"apiKey"`
+		Enabled          *bool                  `json:"enabled"`
+		Transformer      string                 `json:"transformer"`
+		Model            string                 `json:"model"`
+		Remark           string                 `json:"remark"`
+		Weight           *int                   `json:"weight"`
+		Group            *string                `json:"group"`
+		ModelMap         *[]config.ModelMapping `json:"modelMap"`
+		MaxConcurrent    *int                   `json:"maxConcurrent"`
+		RPM              *int                   `json:"rpm"`
+		TPM              *int                   `json:"tpm"`
+		ProxyURL         *string                `json:"proxyUrl"`
+		ConnectTimeout   *int                   `json:"connectTimeout"`
+		FirstByteTimeout *int                   `json:"firstByteTimeout"`
+		TotalTimeout     *int                   `json:"totalTimeout"`
+		AuthStyle        *string                `json:"authStyle"`
+		AuthHeader       *string                `json:"authHeader"`
+		Headers          *[]config.HeaderRule   `json:"headers"`
+		APIKeys          *[]config.APIKey       `json:"apiKeys"`
+		KeyStrategy      *string                `json:"keyStrategy"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -231,6 +368,106 @@ func (h *Handler) updateEndpoint(w http.ResponseWriter, r *http.Request, name st
 		existing.Model = req.Model
 	}
 	existing.Remark = req.Remark
+	if req.Weight != nil {
+		if *req.Weight < 0 {
+			WriteError(w, http.StatusBadRequest, "weight must not be negative")
+			return
+		}
+		existing.Weight = *req.Weight
+	}
+	if req.Group != nil {
+		existing.Group = strings.TrimSpace(*req.Group)
+	}
+	if req.ModelMap != nil {
+		if err := config.ValidateModelMap(*req.ModelMap); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.ModelMap = *req.ModelMap
+	}
+	if req.MaxConcurrent != nil {
+		if *req.MaxConcurrent < 0 {
+			WriteError(w, http.StatusBadRequest, "maxConcurrent must not be negative")
+			return
+		}
+		existing.MaxConcurrent = *req.MaxConcurrent
+	}
+	if req.RPM != nil {
+		if *req.RPM < 0 {
+			WriteError(w, http.StatusBadRequest, "rpm must not be negative")
+			return
+		}
+		existing.RPM = *req.RPM
+	}
+	if req.TPM != nil {
+		if *req.TPM < 0 {
+			WriteError(w, http.StatusBadRequest, "tpm must not be negative")
+			return
+		}
+		existing.TPM = *req.TPM
+	}
+	if req.ProxyURL != nil {
+		proxyURL := strings.TrimSpace(*req.ProxyURL)
+		if err := config.ValidateProxyURL(proxyURL); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.ProxyURL = proxyURL
+	}
+	for _, t := range []struct {
+		value  *int
+		target *int
+	}{
+		{req.ConnectTimeout, &existing.ConnectTimeout},
+		{req.FirstByteTimeout, &existing.FirstByteTimeout},
+		{req.TotalTimeout, &existing.TotalTimeout},
+	} {
+		if t.value == nil {
+			continue
+		}
+		if *t.value < 0 {
+			WriteError(w, http.StatusBadRequest, "timeouts must not be negative")
+			return
+		}
+		*t.target = *t.value
+	}
+	if req.AuthStyle != nil {
+		existing.AuthStyle = *req.AuthStyle
+	}
+	if req.AuthHeader != nil {
+		existing.AuthHeader = strings.TrimSpace(*req.AuthHeader)
+	}
+	if req.AuthStyle != nil || req.AuthHeader != nil {
+		if err := config.ValidateAuthStyle(existing.AuthStyle, existing.AuthHeader); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Headers != nil {
+		if err := config.ValidateHeaderRules(*req.Headers); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.Headers = *req.Headers
+	}
+	if req.APIKeys != nil {
+		// Keys sent back as returned by the API are still masked
+		keys, err := unmaskAPIKeys(*req.APIKeys, existing.APIKeys)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.APIKeys = keys
+	}
+	if req.KeyStrategy != nil {
+		existing.KeyStrategy = *req.KeyStrategy
+	}
+	if req.APIKeys != nil || req.KeyStrategy != nil {
+		if err := config.ValidateAPIKeys(existing.APIKeys, existing.KeyStrategy); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	if strings.TrimSpace(existing.Transformer) == "" {
 		existing.Transformer = "claude"
 	}
@@ -251,7 +488,7 @@ func (h *Handler) updateEndpoint(w http.ResponseWriter, r *http.Request, name st
 		logger.Error("Failed to reload config: %v", err)
 	}
 
-	existing.APIKey = maskAPIKey(existing.APIKey)
+	maskEndpointKeys(existing)
 	WriteSuccess(w, existing)
 }
 
@@ -451,6 +688,8 @@ func (h *Handler) reloadConfig() error {
 		return err
 	}
 
+	// Environment overrides are not stored, so carry them over to the reloaded config
+	cfg.InheritOverrides(h.config)
 	h.config = cfg
 	return h.proxy.UpdateConfig(cfg)
 }
@@ -463,6 +702,45 @@ func maskAPIKey(key string) string {
 	return "****" + key[len(key)-4:]
 }
 
+// maskEndpointKeys masks all API keys of an endpoint before it is returned
+func maskEndpointKeys(ep *storage.Endpoint) {
+	ep.APIKey = maskAPIKey(ep.APIKey)
+	if len(ep.APIKeys) == 0 {
+		return
+	}
+	masked := make([]config.APIKey, len(ep.APIKeys))
+	for i, k := range ep.APIKeys {
+		k.Key = maskAPIKey(k.Key)
+		masked[i] = k
+	}
+	ep.APIKeys = masked
+}
+
+// unmaskAPIKeys replaces masked keys in an update with the stored keys they stand for,
+// preferring the key at the same position
+func unmaskAPIKeys(keys, stored []config.APIKey) ([]config.APIKey, error) {
+	result := make([]config.APIKey, len(keys))
+	for i, k := range keys {
+		k.Key = strings.TrimSpace(k.Key)
+		if strings.HasPrefix(k.Key, "****") {
+			found := false
+			if i < len(stored) && maskAPIKey(stored[i].Key) == k.Key {
+				k.Key, found = stored[i].Key, true
+			}
+			for j := 0; !found && j < len(stored); j++ {
+				if maskAPIKey(stored[j].Key) == k.Key {
+					k.Key, found = stored[j].Key, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("api key %d: masked key does not match a stored key", i+1)
+			}
+		}
+		result[i] = k
+	}
+	return result, nil
+}
+
 // normalizeAPIUrl ensures the API URL has the correct format
 func normalizeAPIUrl(apiUrl string) string {
 	return strings.TrimSuffix(apiUrl, "/")
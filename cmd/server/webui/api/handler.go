@@ -16,6 +16,7 @@ type Handler struct {
 	storage *storage.SQLiteStorage
 	endpoint *service.EndpointService
 	webdav  *service.WebDAVService
+	sessions *sessionStore
 }
 
 // NewHandler creates a new API handler
@@ -26,11 +27,20 @@ func NewHandler(cfg *config.Config, p *proxy.Proxy, s *storage.SQLiteStorage, ve
 		storage: s,
 		endpoint: service.NewEndpointService(cfg, p, s),
 		webdav:  service.NewWebDAVService(cfg, s, version),
+		sessions: newSessionStore(),
 	}
 }
 
-// RegisterRoutes registers all API routes
-func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+// RegisterRoutes registers all API routes behind the CORS and admin auth middleware
+func (h *Handler) RegisterRoutes(root *http.ServeMux) {
+	mux := http.NewServeMux()
+
+	// Admin authentication
+	mux.HandleFunc("/api/auth/session", h.handleAuthSession)
+	mux.HandleFunc("/api/auth/login", h.handleAuthLogin)
+	mux.HandleFunc("/api/auth/logout", h.handleAuthLogout)
+	mux.HandleFunc("/api/auth/password", h.handleAuthPassword)
+
 	// Endpoint management
 	mux.HandleFunc("/api/endpoints", h.handleEndpoints)
 	mux.HandleFunc("/api/endpoints/", h.handleEndpointByName)
@@ -39,18 +49,34 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/endpoints/reorder", h.handleReorderEndpoints)
 	mux.HandleFunc("/api/endpoints/fetch-models", h.handleFetchModels)
 
+	// Client access tokens
+	mux.HandleFunc("/api/tokens", h.handleTokens)
+	mux.HandleFunc("/api/tokens/", h.handleTokenByID)
+
 	// Statistics
 	mux.HandleFunc("/api/stats/summary", h.handleStatsSummary)
 	mux.HandleFunc("/api/stats/daily", h.handleStatsDaily)
 	mux.HandleFunc("/api/stats/weekly", h.handleStatsWeekly)
 	mux.HandleFunc("/api/stats/monthly", h.handleStatsMonthly)
 	mux.HandleFunc("/api/stats/trends", h.handleStatsTrends)
+	mux.HandleFunc("/api/stats/clients", h.handleStatsClients)
 
 	// Configuration
 	mux.HandleFunc("/api/config", h.handleConfig)
 	mux.HandleFunc("/api/config/port", h.handleConfigPort)
 	mux.HandleFunc("/api/config/log-level", h.handleConfigLogLevel)
 
+	// Logs
+	mux.HandleFunc("/api/logs", h.handleLogs)
+
+	// Request log
+	mux.HandleFunc("/api/requests", h.handleRequests)
+
+	// Request capture
+	mux.HandleFunc("/api/capture", h.handleCapture)
+	mux.HandleFunc("/api/capture/arm", h.handleCaptureArm)
+	mux.HandleFunc("/api/replay", h.handleReplay)
+
 	// Real-time events
 	mux.HandleFunc("/api/events", h.handleEvents)
 
@@ -61,4 +87,6 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/webdav/backup", h.handleWebDAVBackup)
 	mux.HandleFunc("/api/webdav/restore", h.handleWebDAVRestore)
 	mux.HandleFunc("/api/webdav/conflict", h.handleWebDAVConflict)
+
+	root.Handle("/api/", RecoveryMiddleware(LoggingMiddleware(h.CORSMiddleware(h.AuthMiddleware(mux)))))
 }
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// handleLogs returns the in-memory logs, optionally only those of one proxy request
+// (requestId), above a level (level, 0-3) or the most recent ones (limit)
+func (h *Handler) handleLogs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		logger.GetLogger().Clear()
+		WriteSuccess(w, map[string]interface{}{
+			"message": "Logs cleared successfully",
+		})
+		return
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	level := logger.DEBUG
+	if levelStr := query.Get("level"); levelStr != "" {
+		n, err := strconv.Atoi(levelStr)
+		if err != nil || n < 0 || n > 3 {
+			WriteError(w, http.StatusBadRequest, "Invalid level (must be 0-3)")
+			return
+		}
+		level = logger.LogLevel(n)
+	}
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 0 {
+			WriteError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	var entries []logger.LogEntry
+	if requestID := query.Get("requestId"); requestID != "" {
+		entries = logger.GetLogger().GetLogsByRequestID(requestID)
+	} else {
+		entries = logger.GetLogger().GetLogs()
+	}
+
+	logs := make([]logger.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Level >= level {
+			logs = append(logs, entry)
+		}
+	}
+	if limit > 0 && len(logs) > limit {
+		logs = logs[len(logs)-limit:]
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"logs": logs,
+	})
+}
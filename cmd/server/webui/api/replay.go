@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/proxy"
+)
+
+// handleReplay re-runs a captured request through the current transformers against an
+// endpoint, or with mock against the recorded upstream response, and diffs the outcome
+// with the recording
+func (h *Handler) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		File      string `json:"file"`
+		RequestID string `json:"requestId"`
+		Attempt   int    `json:"attempt"`
+		Endpoint  string `json:"endpoint"`
+		Mock      bool   `json:"mock"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ex, err := h.proxy.GetCapture().Load(req.File, req.RequestID, req.Attempt)
+	if errors.Is(err, capture.ErrNotFound) {
+		WriteError(w, http.StatusNotFound, "Capture not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.proxy.Replay(r.Context(), ex, proxy.ReplayOptions{Endpoint: req.Endpoint, Mock: req.Mock})
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Info("[REPLAY] %s on %s (mock: %v): request changed: %v, response changed: %v",
+		result.RequestID, result.Endpoint, result.Mock, result.RequestDiff != "", result.ResponseDiff != "")
+
+	WriteSuccess(w, result)
+}
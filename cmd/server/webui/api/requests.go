@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/storage"
+)
+
+const (
+	// defaultRequestsPageSize is the page size of the request log when none is given
+	defaultRequestsPageSize = 50
+	// maxRequestsPageSize is the largest page of the request log returned at once
+	maxRequestsPageSize = 500
+)
+
+// handleRequests returns a page of the request log, newest first. Filters: requestId, endpoint,
+// model, client, status, errors=true and a from/to range (RFC 3339 or YYYY-MM-DD, to inclusive
+// for dates); paging: page and pageSize.
+func (h *Handler) handleRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.RequestLogFilter{
+		RequestID:  query.Get("requestId"),
+		Endpoint:   query.Get("endpoint"),
+		Model:      query.Get("model"),
+		Client:     query.Get("client"),
+		ErrorsOnly: query.Get("errors") == "true",
+	}
+	if statusStr := query.Get("status"); statusStr != "" {
+		n, err := strconv.Atoi(statusStr)
+		if err != nil || n < 0 {
+			WriteError(w, http.StatusBadRequest, "Invalid status")
+			return
+		}
+		filter.Status = n
+	}
+
+	var err error
+	if filter.From, err = parseRequestTime(query.Get("from"), false); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid from (use RFC 3339 or YYYY-MM-DD)")
+		return
+	}
+	if filter.To, err = parseRequestTime(query.Get("to"), true); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid to (use RFC 3339 or YYYY-MM-DD)")
+		return
+	}
+
+	page := 1
+	if pageStr := query.Get("page"); pageStr != "" {
+		n, err := strconv.Atoi(pageStr)
+		if err != nil || n < 1 {
+			WriteError(w, http.StatusBadRequest, "Invalid page")
+			return
+		}
+		page = n
+	}
+	pageSize := defaultRequestsPageSize
+	if sizeStr := query.Get("pageSize"); sizeStr != "" {
+		n, err := strconv.Atoi(sizeStr)
+		if err != nil || n < 1 || n > maxRequestsPageSize {
+			WriteError(w, http.StatusBadRequest, "Invalid pageSize (must be 1-500)")
+			return
+		}
+		pageSize = n
+	}
+	filter.Limit = pageSize
+	filter.Offset = (page - 1) * pageSize
+
+	requests, total, err := h.storage.QueryRequestLogs(filter)
+	if err != nil {
+		logger.Error("Failed to query request log: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to query request log")
+		return
+	}
+	if requests == nil {
+		requests = []storage.RequestLog{}
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"requests": requests,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// parseRequestTime reads a time filter. A plain date is the start of that local day, or the
+// start of the next one when it ends a range.
+func parseRequestTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
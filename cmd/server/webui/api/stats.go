@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/proxy"
 )
 
 // handleStatsSummary returns overall statistics
@@ -32,6 +33,7 @@ func (h *Handler) handleStatsSummary(w http.ResponseWriter, r *http.Request) {
 		"TotalErrors":       totalErrors,
 		"TotalInputTokens":  totalInputTokens,
 		"TotalOutputTokens": totalOutputTokens,
+		"HedgedRequests":    h.proxy.GetStats().GetHedgedRequests(),
 		"Endpoints":         endpointStats,
 	})
 }
@@ -173,6 +175,51 @@ func (h *Handler) handleStatsTrends(w http.ResponseWriter, r *http.Request) {
 	WriteSuccess(w, trends)
 }
 
+// handleStatsClients returns the usage per client (access token) in a date range,
+// the current month by default
+func (h *Handler) handleStatsClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	now := time.Now()
+	startDate := r.URL.Query().Get("startDate")
+	if startDate == "" {
+		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+	}
+	endDate := r.URL.Query().Get("endDate")
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+
+	stats, err := h.storage.GetClientStats(startDate, endDate)
+	if err != nil {
+		logger.Error("Failed to get client stats: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get client stats")
+		return
+	}
+
+	clients := make(map[string]*proxy.ClientUsage)
+	for _, stat := range stats {
+		usage, ok := clients[stat.ClientName]
+		if !ok {
+			usage = &proxy.ClientUsage{}
+			clients[stat.ClientName] = usage
+		}
+		usage.Requests += stat.Requests
+		usage.Errors += stat.Errors
+		usage.InputTokens += stat.InputTokens
+		usage.OutputTokens += stat.OutputTokens
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"startDate": startDate,
+		"endDate":   endDate,
+		"clients":   clients,
+	})
+}
+
 // getStatsForPeriod retrieves statistics for a date range
 func (h *Handler) getStatsForPeriod(startDate, endDate string) (map[string]interface{}, error) {
 	allStats, err := h.storage.GetAllStats()
@@ -3,8 +3,10 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
 )
 
@@ -80,6 +82,11 @@ func (h *Handler) handleFetchModels(w http.ResponseWriter, r *http.Request) {
 		APIUrl      string `json:"apiUrl"`
 		APIKey      string `json:"apiKey"`
 		Transformer string `json:"transformer"`
+		ProxyURL    string `json:"proxyUrl"`
+		// Timeouts of the endpoint being edited, in seconds
+		ConnectTimeout   int `json:"connectTimeout"`
+		FirstByteTimeout int `json:"firstByteTimeout"`
+		TotalTimeout     int `json:"totalTimeout"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -87,7 +94,23 @@ func (h *Handler) handleFetchModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resultJSON := h.endpoint.FetchModels(req.APIUrl, req.APIKey, req.Transformer)
+	req.ProxyURL = strings.TrimSpace(req.ProxyURL)
+	if err := config.ValidateProxyURL(req.ProxyURL); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.ConnectTimeout < 0 || req.FirstByteTimeout < 0 || req.TotalTimeout < 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid timeouts (values must not be negative)")
+		return
+	}
+
+	resultJSON := h.endpoint.FetchModelsWithProxy(req.APIUrl, req.APIKey, req.Transformer, config.Endpoint{
+		ProxyURL:         req.ProxyURL,
+		ConnectTimeout:   req.ConnectTimeout,
+		FirstByteTimeout: req.FirstByteTimeout,
+		TotalTimeout:     req.TotalTimeout,
+	})
 	var parsed struct {
 		Success bool     `json:"success"`
 		Message string   `json:"message"`
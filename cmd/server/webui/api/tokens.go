@@ -0,0 +1,254 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/storage"
+)
+
+// accessTokenPrefix marks tokens issued by ccNexus
+const accessTokenPrefix = "sk-ccnx-"
+
+// handleTokens handles GET (list) and POST (create) for access tokens
+func (h *Handler) handleTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listTokens(w, r)
+	case http.MethodPost:
+		h.createToken(w, r)
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleTokenByID handles PUT (update) and DELETE (revoke) for a specific access token
+func (h *Handler) handleTokenByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/tokens/"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid token id")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		h.updateToken(w, r, id)
+	case http.MethodDelete:
+		h.revokeToken(w, r, id)
+	default:
+		WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// listTokens returns all issued access tokens, without the tokens themselves
+func (h *Handler) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.storage.GetAccessTokens()
+	if err != nil {
+		logger.Error("Failed to get access tokens: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get access tokens")
+		return
+	}
+	if tokens == nil {
+		tokens = []storage.AccessToken{}
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"tokens": tokens,
+	})
+}
+
+// createToken issues a new access token. The token is only returned in this response.
+func (h *Handler) createToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string             `json:"name"`
+		Endpoints []string           `json:"endpoints"`
+		ExpiresAt *time.Time         `json:"expiresAt"`
+		Quota     config.ClientQuota `json:"quota"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		WriteError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		WriteError(w, http.StatusBadRequest, "expiresAt must be in the future")
+		return
+	}
+	if err := h.validateTokenEndpoints(req.Endpoints); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := config.ValidateClientQuota(req.Quota); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.storage.GetAccessTokens()
+	if err != nil {
+		logger.Error("Failed to get access tokens: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get access tokens")
+		return
+	}
+	for _, t := range tokens {
+		if t.Name == req.Name {
+			WriteError(w, http.StatusConflict, "Token with this name already exists")
+			return
+		}
+	}
+
+	secret, err := generateAccessToken()
+	if err != nil {
+		logger.Error("Failed to generate access token: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to generate access token")
+		return
+	}
+
+	token := &storage.AccessToken{
+		Name:      req.Name,
+		TokenHash: config.HashToken(secret),
+		TokenHint: secret[len(secret)-4:],
+		Endpoints: req.Endpoints,
+		ExpiresAt: req.ExpiresAt,
+		Quota:     req.Quota,
+	}
+	if err := h.storage.SaveAccessToken(token); err != nil {
+		logger.Error("Failed to save access token: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to save access token")
+		return
+	}
+
+	// Update proxy config
+	if err := h.reloadConfig(); err != nil {
+		logger.Error("Failed to reload config: %v", err)
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"token":       secret,
+		"accessToken": token,
+	})
+}
+
+// updateToken changes the endpoints, expiry or quota of an access token
+func (h *Handler) updateToken(w http.ResponseWriter, r *http.Request, id int64) {
+	var req struct {
+		Endpoints *[]string           `json:"endpoints"`
+		ExpiresAt *time.Time          `json:"expiresAt"`
+		Quota     *config.ClientQuota `json:"quota"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := h.storage.GetAccessTokens()
+	if err != nil {
+		logger.Error("Failed to get access tokens: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to get access tokens")
+		return
+	}
+
+	var existing *storage.AccessToken
+	for i := range tokens {
+		if tokens[i].ID == id {
+			existing = &tokens[i]
+			break
+		}
+	}
+	if existing == nil {
+		WriteError(w, http.StatusNotFound, "Token not found")
+		return
+	}
+
+	if req.Endpoints != nil {
+		if err := h.validateTokenEndpoints(*req.Endpoints); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.Endpoints = *req.Endpoints
+	}
+	if req.ExpiresAt != nil {
+		existing.ExpiresAt = req.ExpiresAt
+	}
+	if req.Quota != nil {
+		if err := config.ValidateClientQuota(*req.Quota); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		existing.Quota = *req.Quota
+	}
+
+	if err := h.storage.UpdateAccessToken(existing); err != nil {
+		logger.Error("Failed to update access token: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to update access token")
+		return
+	}
+
+	// Update proxy config
+	if err := h.reloadConfig(); err != nil {
+		logger.Error("Failed to reload config: %v", err)
+	}
+
+	WriteSuccess(w, existing)
+}
+
+// validateTokenEndpoints checks that a token's endpoint list only names existing endpoints
+func (h *Handler) validateTokenEndpoints(names []string) error {
+	known := make(map[string]bool)
+	for _, ep := range h.config.GetEndpoints() {
+		known[ep.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("Unknown endpoint: %s", name)
+		}
+	}
+	return nil
+}
+
+// revokeToken revokes an access token
+func (h *Handler) revokeToken(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := h.storage.RevokeAccessToken(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusNotFound, "Token not found or already revoked")
+			return
+		}
+		logger.Error("Failed to revoke access token: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Failed to revoke access token")
+		return
+	}
+
+	// Update proxy config
+	if err := h.reloadConfig(); err != nil {
+		logger.Error("Failed to reload config: %v", err)
+	}
+
+	WriteSuccess(w, map[string]interface{}{
+		"message": "Token revoked successfully",
+	})
+}
+
+// generateAccessToken creates a random access token
+func generateAccessToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return accessTokenPrefix + hex.EncodeToString(buf), nil
+}
@@ -207,6 +207,7 @@ func (h *Handler) handleWebDAVRestore(w http.ResponseWriter, r *http.Request) {
 
 	// Use the same semantics as desktop: update proxy config after merge
 	if err := h.webdav.RestoreFromWebDAV(filename, choice, func(cfg *config.Config) error {
+		cfg.InheritOverrides(h.config)
 		h.config = cfg
 		return h.proxy.UpdateConfig(cfg)
 	}); err != nil {
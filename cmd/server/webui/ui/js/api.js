@@ -2,6 +2,8 @@
 class APIClient {
     constructor(baseURL = '/api') {
         this.baseURL = baseURL;
+        this.csrfToken = null;
+        this.onUnauthorized = null;
     }
 
     async request(method, path, data = null) {
@@ -16,10 +18,18 @@ class APIClient {
             options.body = JSON.stringify(data);
         }
 
+        if (this.csrfToken && method !== 'GET') {
+            options.headers['X-CSRF-Token'] = this.csrfToken;
+        }
+
         try {
             const response = await fetch(`${this.baseURL}${path}`, options);
             const result = await response.json();
 
+            if (response.status === 401 && this.onUnauthorized && !path.startsWith('/auth/')) {
+                this.onUnauthorized();
+            }
+
             if (!response.ok) {
                 throw new Error(result.error || 'Request failed');
             }
@@ -31,6 +41,29 @@ class APIClient {
         }
     }
 
+    // Admin authentication
+    async getSession() {
+        const session = await this.request('GET', '/auth/session');
+        this.csrfToken = session.csrfToken || null;
+        return session;
+    }
+
+    async login(password) {
+        const session = await this.request('POST', '/auth/login', { password });
+        this.csrfToken = session.csrfToken;
+        return session;
+    }
+
+    async logout() {
+        const result = await this.request('POST', '/auth/logout');
+        this.csrfToken = null;
+        return result;
+    }
+
+    async changePassword(currentPassword, newPassword) {
+        return this.request('PUT', '/auth/password', { currentPassword, newPassword });
+    }
+
     // Endpoint management
     async getEndpoints() {
         return this.request('GET', '/endpoints');
@@ -68,8 +101,14 @@ class APIClient {
         return this.request('POST', '/endpoints/switch', { name });
     }
 
-    async fetchModels(apiUrl, apiKey, transformer) {
-        return this.request('POST', '/endpoints/fetch-models', { apiUrl, apiKey, transformer });
+    async fetchModels(apiUrl, apiKey, transformer, endpoint) {
+        return this.request('POST', '/endpoints/fetch-models', {
+            apiUrl, apiKey, transformer,
+            proxyUrl: endpoint?.proxyUrl || '',
+            connectTimeout: endpoint?.connectTimeout || 0,
+            firstByteTimeout: endpoint?.firstByteTimeout || 0,
+            totalTimeout: endpoint?.totalTimeout || 0
+        });
     }
 
     // Statistics
@@ -357,10 +357,11 @@ class Endpoints {
         document.getElementById('close-modal').addEventListener('click', () => this.closeModal());
         document.getElementById('cancel-btn').addEventListener('click', () => this.closeModal());
         document.getElementById('save-btn').addEventListener('click', () => this.saveEndpoint(isEdit, endpoint?.name));
-        document.getElementById('fetch-models-btn').addEventListener('click', () => this.fetchModels());
+        document.getElementById('fetch-models-btn').addEventListener('click', () => this.fetchModels(endpoint));
     }
 
-    async fetchModels() {
+    // Fetches through the proxy and with the timeouts of the endpoint being edited, if any
+    async fetchModels(endpoint) {
         const apiUrlInput = document.querySelector('input[name="apiUrl"]');
         const apiKeyInput = document.querySelector('input[name="apiKey"]');
         const transformerSelect = document.querySelector('select[name="transformer"]');
@@ -380,7 +381,7 @@ class Endpoints {
             fetchBtn.disabled = true;
             fetchBtn.textContent = 'Fetching...';
 
-            const result = await api.fetchModels(apiUrl, apiKey, transformer);
+            const result = await api.fetchModels(apiUrl, apiKey, transformer, endpoint);
 
             if (result.models && result.models.length > 0) {
                 // Show model selection modal
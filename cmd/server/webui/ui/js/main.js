@@ -1,3 +1,4 @@
+import { api } from './api.js';
 import { router } from './router.js';
 import { state } from './state.js';
 import { dashboard } from './components/dashboard.js';
@@ -55,8 +56,80 @@ function initRealtime() {
     };
 }
 
+// Show the login dialog and resolve once the admin has logged in
+function showLogin() {
+    const container = document.getElementById('modal-container');
+    if (container.querySelector('#login-form')) {
+        return new Promise(() => {});
+    }
+
+    container.innerHTML = `
+        <div class="modal-overlay">
+            <div class="modal">
+                <div class="modal-header">
+                    <h2 class="modal-title">Admin Login</h2>
+                </div>
+                <form id="login-form">
+                    <div class="modal-body">
+                        <div class="form-group">
+                            <label class="form-label" for="login-password">Password</label>
+                            <input type="password" id="login-password" class="form-input" autocomplete="current-password" required>
+                        </div>
+                        <p id="login-error" style="color: var(--danger-color);"></p>
+                    </div>
+                    <div class="modal-footer">
+                        <button type="submit" class="btn btn-primary">Log in</button>
+                    </div>
+                </form>
+            </div>
+        </div>
+    `;
+
+    const form = container.querySelector('#login-form');
+    const input = container.querySelector('#login-password');
+    input.focus();
+
+    return new Promise((resolve) => {
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            try {
+                await api.login(input.value);
+                container.innerHTML = '';
+                resolve();
+            } catch (error) {
+                container.querySelector('#login-error').textContent = error.message;
+                input.select();
+            }
+        });
+    });
+}
+
+// Make sure the admin is logged in when the API asks for it
+async function initAuth() {
+    const session = await api.getSession();
+    if (!session.authRequired && !session.authenticated) {
+        document.getElementById('view-container').innerHTML =
+            '<div class="empty-state"><p>No admin password is set yet, so the admin API only accepts requests from this machine. Set CCNEXUS_ADMIN_PASSWORD, or set a password from the server itself with PUT /api/auth/password.</p></div>';
+        return false;
+    }
+    if (session.authRequired && !session.authenticated) {
+        if (!session.passwordLogin) {
+            document.getElementById('view-container').innerHTML =
+                '<div class="empty-state"><p>The admin API only accepts the admin token. Set CCNEXUS_ADMIN_PASSWORD to log in here.</p></div>';
+            return false;
+        }
+        await showLogin();
+    }
+
+    // A session that expires later brings the login dialog back
+    api.onUnauthorized = () => {
+        showLogin().then(() => router.navigate(state.get('currentView') || 'dashboard'));
+    };
+    return true;
+}
+
 // Initialize application
-function init() {
+async function init() {
     // Register routes
     router.register('dashboard', dashboard);
     router.register('endpoints', endpoints);
@@ -67,6 +140,11 @@ function init() {
     // Initialize theme
     initTheme();
 
+    // Log in before anything talks to the API
+    if (!await initAuth()) {
+        return;
+    }
+
     // Initialize router
     router.init();
 
@@ -0,0 +1,247 @@
+// Package capture records full proxy exchanges for debugging: the client request, the request
+// sent upstream, the raw upstream response (including every SSE line) and the response sent back
+// to the client. Credentials and configured patterns are redacted before anything is written.
+// Every Exchange method is a no-op on a nil exchange, so callers need no checks of their own.
+package capture
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// maxBodySize is the largest body kept of one message; the rest is cut off
+const maxBodySize = 4 << 20
+
+// Message is one request or response of an exchange
+type Message struct {
+	Method    string      `json:"method,omitempty"`
+	URL       string      `json:"url,omitempty"`
+	Status    int         `json:"status,omitempty"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      string      `json:"body"`
+	Truncated bool        `json:"truncated,omitempty"` // the body was longer than 4 MB
+}
+
+// Exchange is one upstream attempt of a proxy request with everything that went over the wire
+type Exchange struct {
+	Time             time.Time `json:"time"`
+	RequestID        string    `json:"requestId"`
+	Attempt          int       `json:"attempt"`
+	Endpoint         string    `json:"endpoint"`
+	Transformer      string    `json:"transformer"`
+	ClientFormat     string    `json:"clientFormat"`
+	Error            string    `json:"error,omitempty"`
+	ClientRequest    *Message  `json:"clientRequest"`
+	UpstreamRequest  *Message  `json:"upstreamRequest"`
+	UpstreamResponse *Message  `json:"upstreamResponse,omitempty"`
+	ClientResponse   *Message  `json:"clientResponse,omitempty"`
+}
+
+// NewRequest captures a request with its body, which the request itself may already have consumed
+func NewRequest(r *http.Request, body []byte) *Message {
+	m := &Message{Method: r.Method, URL: r.URL.String(), Header: r.Header.Clone()}
+	m.append(body)
+	return m
+}
+
+// append adds to the body up to maxBodySize
+func (m *Message) append(b []byte) {
+	if m.Truncated {
+		return
+	}
+	if room := maxBodySize - len(m.Body); len(b) > room {
+		b = b[:room]
+		m.Truncated = true
+	}
+	m.Body += string(b)
+}
+
+// SetUpstreamResponse records the status and headers of the upstream response
+func (e *Exchange) SetUpstreamResponse(status int, header http.Header) {
+	if e == nil {
+		return
+	}
+	e.UpstreamResponse = &Message{Status: status, Header: header.Clone()}
+}
+
+// AppendUpstreamBody adds raw upstream response bytes, such as one SSE line
+func (e *Exchange) AppendUpstreamBody(b []byte) {
+	if e == nil || e.UpstreamResponse == nil {
+		return
+	}
+	e.UpstreamResponse.append(b)
+}
+
+// SetClientResponse records the status and headers of the response sent to the client
+func (e *Exchange) SetClientResponse(status int, header http.Header) {
+	if e == nil {
+		return
+	}
+	e.ClientResponse = &Message{Status: status, Header: header.Clone()}
+}
+
+// AppendClientBody adds bytes sent to the client, such as one transformed SSE event
+func (e *Exchange) AppendClientBody(b []byte) {
+	if e == nil || e.ClientResponse == nil {
+		return
+	}
+	e.ClientResponse.append(b)
+}
+
+// SetError records why the attempt ended without a usable response
+func (e *Exchange) SetError(msg string) {
+	if e == nil {
+		return
+	}
+	e.Error = msg
+}
+
+// Recorder decides which requests are captured and writes their exchanges according to the
+// current capture settings
+type Recorder struct {
+	mu         sync.Mutex
+	cfg        config.CaptureConfig
+	configured bool
+	defaultDir string
+	secrets    func() []string // credentials redacted wherever they appear
+	redactor   *redactor
+	writer     *writer
+	armed      int64 // requests still captured by Arm
+}
+
+// NewRecorder creates a recorder that redacts the credentials returned by secrets.
+// Captures go to defaultDir unless the settings name a directory.
+func NewRecorder(defaultDir string, secrets func() []string) *Recorder {
+	return &Recorder{defaultDir: defaultDir, secrets: secrets}
+}
+
+// SetDefaultDir changes the directory used when the settings name none
+func (r *Recorder) SetDefaultDir(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultDir = dir
+	r.reset()
+}
+
+// Configure applies the capture settings, reopening the output when they changed
+func (r *Recorder) Configure(cfg config.CaptureConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.configured && reflect.DeepEqual(cfg, r.cfg) {
+		return
+	}
+	r.cfg = cfg
+	r.configured = true
+	r.reset()
+}
+
+// reset rebuilds the redactor and output from the current settings; the caller holds mu
+func (r *Recorder) reset() {
+	if r.writer != nil {
+		r.writer.close()
+	}
+	r.redactor = newRedactor(r.cfg.RedactHeaders, r.cfg.RedactPatterns)
+	r.writer = newWriter(r.dir(), r.cfg.Format, int64(r.cfg.MaxFileSizeMB)<<20, r.cfg.MaxFiles)
+}
+
+// dir returns the capture directory; the caller holds mu
+func (r *Recorder) dir() string {
+	if r.cfg.Dir != "" {
+		return r.cfg.Dir
+	}
+	if r.defaultDir != "" {
+		return r.defaultDir
+	}
+	return "captures"
+}
+
+// Dir returns the directory captures are written to
+func (r *Recorder) Dir() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dir()
+}
+
+// Arm captures the next n requests whatever the settings; 0 disarms
+func (r *Recorder) Arm(n int) {
+	atomic.StoreInt64(&r.armed, int64(n))
+}
+
+// Armed returns how many of the next requests are still captured by Arm
+func (r *Recorder) Armed() int {
+	return int(atomic.LoadInt64(&r.armed))
+}
+
+// TakeRequest decides whether all attempts of a new request are captured: when capture is
+// enabled without an endpoint list, or when the request is one of those armed
+func (r *Recorder) TakeRequest() bool {
+	r.mu.Lock()
+	all := r.cfg.Enabled && len(r.cfg.Endpoints) == 0
+	r.mu.Unlock()
+	if all {
+		return true
+	}
+	for {
+		n := atomic.LoadInt64(&r.armed)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&r.armed, n, n-1) {
+			return true
+		}
+	}
+}
+
+// CapturesEndpoint reports whether attempts on an endpoint are captured by the endpoint list
+func (r *Recorder) CapturesEndpoint(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.cfg.Enabled {
+		return false
+	}
+	for _, ep := range r.cfg.Endpoints {
+		if ep == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Record redacts an exchange and writes it out. Failures are logged, never returned, so
+// capture cannot break a request.
+func (r *Recorder) Record(ex *Exchange) {
+	if ex == nil {
+		return
+	}
+	secrets := r.secrets()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil {
+		return
+	}
+	r.redactor.exchange(ex, secrets)
+	if err := r.writer.write(ex); err != nil {
+		logger.Error("[CAPTURE] Failed to write %s to %s: %v", ex.RequestID, r.dir(), err)
+	}
+}
+
+// Files lists the capture files, newest first
+func (r *Recorder) Files() ([]FileInfo, error) {
+	return listFiles(r.Dir())
+}
+
+// Close closes the output file
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer != nil {
+		r.writer.close()
+	}
+}
@@ -0,0 +1,68 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when a capture file or exchange does not exist
+var ErrNotFound = errors.New("capture not found")
+
+// Load reads an exchange from a capture file in the capture directory. In a JSONL file the
+// exchange is looked up by request ID and attempt; attempt 0 picks the last attempt of the
+// request, the one whose response reached the client. A per-exchange file needs neither.
+func (r *Recorder) Load(name, requestID string, attempt int) (*Exchange, error) {
+	if name == "" || name != filepath.Base(name) || !isCaptureFile(name) {
+		return nil, fmt.Errorf("invalid capture file name: %q", name)
+	}
+	f, err := os.Open(filepath.Join(r.Dir(), name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if !strings.HasSuffix(name, ".jsonl") {
+		var ex Exchange
+		if err := json.NewDecoder(f).Decode(&ex); err != nil {
+			return nil, fmt.Errorf("invalid capture file %s: %w", name, err)
+		}
+		if (requestID != "" && ex.RequestID != requestID) || (attempt > 0 && ex.Attempt != attempt) {
+			return nil, ErrNotFound
+		}
+		return &ex, nil
+	}
+
+	if requestID == "" {
+		return nil, fmt.Errorf("requestId is required for %s", name)
+	}
+	var found *Exchange
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && strings.Contains(string(line), `"requestId":"`+requestID+`"`) {
+			var ex Exchange
+			if json.Unmarshal(line, &ex) == nil && ex.RequestID == requestID && (attempt == 0 || ex.Attempt == attempt) {
+				found = &ex
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
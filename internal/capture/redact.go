@@ -0,0 +1,86 @@
+package capture
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces every secret in a capture
+const Redacted = "[REDACTED]"
+
+// credentialHeaders are always redacted; they carry the client's and the endpoints' credentials
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"X-Goog-Api-Key",
+	"Api-Key",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// redactor removes credentials and configured patterns from exchanges
+type redactor struct {
+	headers  map[string]bool // canonical header names whose values are dropped
+	patterns []*regexp.Regexp
+}
+
+// newRedactor creates a redactor for the credential headers plus the given ones. Patterns that
+// do not compile are skipped; the settings are validated before they get here.
+func newRedactor(headers, patterns []string) *redactor {
+	rd := &redactor{headers: make(map[string]bool)}
+	for _, name := range credentialHeaders {
+		rd.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range headers {
+		rd.headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			rd.patterns = append(rd.patterns, re)
+		}
+	}
+	return rd
+}
+
+// exchange redacts all messages of an exchange in place
+func (rd *redactor) exchange(ex *Exchange, secrets []string) {
+	for _, m := range []*Message{ex.ClientRequest, ex.UpstreamRequest, ex.UpstreamResponse, ex.ClientResponse} {
+		if m != nil {
+			rd.message(m, secrets)
+		}
+	}
+	ex.Error = rd.text(ex.Error, secrets)
+}
+
+// message redacts the URL, headers and body of a message
+func (rd *redactor) message(m *Message, secrets []string) {
+	m.URL = rd.text(m.URL, secrets)
+	for name, values := range m.Header {
+		if rd.headers[http.CanonicalHeaderKey(name)] {
+			m.Header[name] = []string{Redacted}
+			continue
+		}
+		for i, value := range values {
+			values[i] = rd.text(value, secrets)
+		}
+	}
+	m.Body = rd.text(m.Body, secrets)
+}
+
+// text replaces the secrets and pattern matches in s
+func (rd *redactor) text(s string, secrets []string) string {
+	if s == "" {
+		return s
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	for _, re := range rd.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,189 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// jsonlName is the JSONL file currently written to; rotated files get a timestamp
+const jsonlName = "capture.jsonl"
+
+// fileTimeLayout orders capture file names by time
+const fileTimeLayout = "20060102-150405.000"
+
+// FileInfo describes one capture file
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// writer writes exchanges to a rotating JSONL file or to one file each
+type writer struct {
+	dir      string
+	format   string
+	maxSize  int64 // rotation size of the JSONL file, 0 for no limit
+	maxFiles int
+	file     *os.File // open JSONL file, nil until the first write
+	size     int64
+}
+
+// newWriter creates a writer; nothing is created on disk before the first exchange
+func newWriter(dir, format string, maxSize int64, maxFiles int) *writer {
+	return &writer{dir: dir, format: format, maxSize: maxSize, maxFiles: maxFiles}
+}
+
+// write writes one exchange
+func (w *writer) write(ex *Exchange) error {
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return err
+	}
+	if w.format == config.CaptureFormatFiles {
+		return w.writeFile(ex)
+	}
+	return w.writeLine(ex)
+}
+
+// writeLine appends an exchange to the JSONL file, rotating it first if it would grow past maxSize
+func (w *writer) writeLine(ex *Exchange) error {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if w.file == nil {
+		f, err := os.OpenFile(filepath.Join(w.dir, jsonlName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.file, w.size = f, info.Size()
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// rotate renames the full JSONL file, starts a new one and removes the oldest rotated files
+func (w *writer) rotate() error {
+	w.file.Close()
+	w.file = nil
+	rotated := fmt.Sprintf("capture-%s.jsonl", time.Now().Format(fileTimeLayout))
+	if err := os.Rename(filepath.Join(w.dir, jsonlName), filepath.Join(w.dir, rotated)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(w.dir, jsonlName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	w.file, w.size = f, 0
+
+	files, err := listFiles(w.dir)
+	if err != nil {
+		return err
+	}
+	var old []FileInfo
+	for _, file := range files {
+		if file.Name != jsonlName {
+			old = append(old, file)
+		}
+	}
+	for i := w.maxFiles; i < len(old); i++ {
+		os.Remove(filepath.Join(w.dir, old[i].Name))
+	}
+	return nil
+}
+
+// writeFile writes an exchange to a file of its own and removes the oldest files while the
+// directory holds more than maxSize * maxFiles
+func (w *writer) writeFile(ex *Exchange) error {
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Request IDs are plain tokens, but ':' is not allowed in Windows file names
+	id := strings.ReplaceAll(ex.RequestID, ":", "_")
+	name := fmt.Sprintf("%s-%s-%d.json", ex.Time.Format(fileTimeLayout), id, ex.Attempt)
+	if err := os.WriteFile(filepath.Join(w.dir, name), data, 0600); err != nil {
+		return err
+	}
+
+	if w.maxSize <= 0 {
+		return nil
+	}
+	files, err := listFiles(w.dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	for i := len(files) - 1; i > 0 && total > w.maxSize*int64(w.maxFiles); i-- {
+		if os.Remove(filepath.Join(w.dir, files[i].Name)) == nil {
+			total -= files[i].Size
+		}
+	}
+	return nil
+}
+
+// close closes the JSONL file
+func (w *writer) close() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
+
+// isCaptureFile checks if a file name is one the writer creates
+func isCaptureFile(name string) bool {
+	return strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".json")
+}
+
+// listFiles returns the capture files of a directory, newest first
+func listFiles(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isCaptureFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].ModTime.After(files[j].ModTime)
+		}
+		return files[i].Name > files[j].Name
+	})
+	return files, nil
+}
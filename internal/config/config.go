@@ -1,22 +1,131 @@
 package config
 
 import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Endpoint represents a single API endpoint configuration
 type Endpoint struct {
-	Name        string `json:"name"`
-	APIUrl      string `json:"apiUrl"`
-	APIKey      string `json:"apiKey"`
-	Enabled     bool   `json:"enabled"`
-	Transformer string `json:"transformer,omitempty"` // Transformer type: claude, openai, gemini, deepseek
-	Model       string `json:"model,omitempty"`       // Target model name for non-Claude APIs
-	Remark      string `json:"remark,omitempty"`      // Optional remark for the endpoint
+	Name             string         `json:"name"`
+	APIUrl           string         `json:"apiUrl"`
+	APIKey           string         `json:"apiKey"`
+	Enabled          bool           `json:"enabled"`
+	Transformer      string         `json:"transformer,omitempty"`      // Transformer type: claude, openai, gemini, deepseek
+	Model            string         `json:"model,omitempty"`            // Target model name for non-Claude APIs
+	Remark           string         `json:"remark,omitempty"`           // Optional remark for the endpoint
+	Weight           int            `json:"weight,omitempty"`           // Relative weight for the weighted strategy (0 means 1)
+	Group            string         `json:"group,omitempty"`            // Endpoint group referenced by routing rules
+	ModelMap         []ModelMapping `json:"modelMap,omitempty"`         // Per-model overrides checked before Model
+	MaxConcurrent    int            `json:"maxConcurrent,omitempty"`    // Maximum parallel requests (0 means unlimited)
+	RPM              int            `json:"rpm,omitempty"`              // Requests per minute (0 means unlimited)
+	TPM              int            `json:"tpm,omitempty"`              // Input plus output tokens per minute (0 means unlimited)
+	ProxyURL         string         `json:"proxyUrl,omitempty"`         // Outbound proxy for this endpoint ("" uses the global proxy, "direct" bypasses it)
+	ConnectTimeout   int            `json:"connectTimeout,omitempty"`   // Seconds to establish a connection (0 uses transport.dialTimeout)
+	FirstByteTimeout int            `json:"firstByteTimeout,omitempty"` // Seconds to wait for response headers (0 uses transport.responseHeaderTimeout)
+	TotalTimeout     int            `json:"totalTimeout,omitempty"`     // Seconds for the whole request including the stream (0 means 300)
+	AuthStyle        string         `json:"authStyle,omitempty"`        // How the API key is sent: bearer, x-api-key, x-goog-api-key, query, header ("" picks by transformer)
+	AuthHeader       string         `json:"authHeader,omitempty"`       // Header name for the "header" auth style
+	Headers          []HeaderRule   `json:"headers,omitempty"`          // Upstream header changes applied after the client headers are copied
+	APIKeys          []APIKey       `json:"apiKeys,omitempty"`          // Additional keys for the same base URL, rotated together with APIKey
+	KeyStrategy      string         `json:"keyStrategy,omitempty"`      // Key selection: round_robin (default) or least_recent_429
+}
+
+// Key selection strategies for endpoints with several API keys
+const (
+	KeyStrategyRoundRobin     = "round_robin"      // Take turns
+	KeyStrategyLeastRecent429 = "least_recent_429" // Prefer the key that was rate limited longest ago
+)
+
+// Log output formats
+const (
+	LogFormatText = "text" // Icon, level and message per line
+	LogFormatJSON = "json" // One JSON object per line with structured fields
+)
+
+// APIKey is an additional API key of an endpoint
+type APIKey struct {
+	Key     string `json:"key"`
+	Label   string `json:"label,omitempty"` // Optional name shown instead of the masked key
+	Enabled bool   `json:"enabled"`
+}
+
+// Keys returns the API keys requests may use: APIKey followed by the enabled additional keys
+func (e Endpoint) Keys() []string {
+	keys := make([]string, 0, 1+len(e.APIKeys))
+	seen := make(map[string]bool)
+	if e.APIKey != "" {
+		keys = append(keys, e.APIKey)
+		seen[e.APIKey] = true
+	}
+	for _, k := range e.APIKeys {
+		if k.Enabled && k.Key != "" && !seen[k.Key] {
+			keys = append(keys, k.Key)
+			seen[k.Key] = true
+		}
+	}
+	return keys
+}
+
+// Auth styles for sending the API key upstream
+const (
+	AuthStyleBearer     = "bearer"         // Authorization: Bearer <key>
+	AuthStyleAPIKey     = "x-api-key"      // x-api-key: <key>
+	AuthStyleGoogAPIKey = "x-goog-api-key" // x-goog-api-key: <key>
+	AuthStyleQuery      = "query"          // ?key=<key>
+	AuthStyleHeader     = "header"         // <AuthHeader>: <key>
+)
+
+// Header rule actions
+const (
+	HeaderSet    = "set"    // Add the header or override the client's value
+	HeaderAdd    = "add"    // Append a value, keeping the client's
+	HeaderRemove = "remove" // Strip the header
+)
+
+// HeaderRule changes one upstream request header
+type HeaderRule struct {
+	Action string `json:"action"`          // set, add or remove
+	Name   string `json:"name"`            // Header name, e.g. anthropic-beta
+	Value  string `json:"value,omitempty"` // Header value for set and add
+}
+
+// ModelMapping sends requests whose model matches Pattern to the Model of the upstream
+type ModelMapping struct {
+	Pattern string `json:"pattern"` // Glob pattern matched against the requested model, e.g. *haiku*
+	Model   string `json:"model"`   // Target model name sent upstream
+}
+
+// MatchModel reports whether a requested model matches a glob pattern (case-insensitive)
+func MatchModel(pattern, model string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(model))
+	return err == nil && ok
+}
+
+// ResolveModel returns the target model for a requested model: the first matching
+// mapping wins, otherwise Model is used as the default (empty keeps the requested model)
+func (e Endpoint) ResolveModel(requested string) string {
+	if requested != "" {
+		for _, m := range e.ModelMap {
+			if MatchModel(m.Pattern, requested) {
+				return m.Model
+			}
+		}
+	}
+	return e.Model
 }
 
 // WebDAVConfig represents WebDAV synchronization configuration
@@ -42,16 +151,260 @@ type TerminalConfig struct {
 	ProjectDirs      []string `json:"projectDirs"`      // Project directories
 }
 
+// DirectProxy is the endpoint proxy value that connects directly, ignoring the global proxy
+const DirectProxy = "direct"
+
 // ProxyConfig represents HTTP proxy configuration
 type ProxyConfig struct {
 	URL string `json:"url"` // Proxy URL, e.g., http://127.0.0.1:7890 or socks5://127.0.0.1:1080
 }
 
+// RoutingRule sends requests whose model matches Pattern to the endpoints of Group
+type RoutingRule struct {
+	Pattern string `json:"pattern"` // Glob pattern matched against the requested model, e.g. claude-3-5-haiku*
+	Group   string `json:"group"`   // Endpoint group that serves matching requests
+}
+
+// CircuitBreakerConfig represents per-endpoint circuit breaker configuration
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failureThreshold"` // Consecutive failures before the circuit opens (0 disables)
+	CooldownSeconds  int `json:"cooldownSeconds"`  // Seconds an open circuit waits before a probe request
+}
+
+// StreamingConfig represents streaming response configuration
+type StreamingConfig struct {
+	FirstEventTimeout int `json:"firstEventTimeout"` // Seconds to wait for the first upstream event before failing over (0 disables)
+}
+
+// HedgingConfig represents request hedging configuration
+type HedgingConfig struct {
+	DelayMs int `json:"delayMs"` // Milliseconds without a response before the request is also sent to a second endpoint (0 disables)
+}
+
+// ConcurrencyConfig represents the wait queue used when endpoints hit MaxConcurrent
+type ConcurrencyConfig struct {
+	QueueSize    int `json:"queueSize"`    // Maximum requests waiting per endpoint
+	QueueTimeout int `json:"queueTimeout"` // Seconds a request waits for a free slot
+}
+
+// StickyConfig represents conversation affinity configuration
+type StickyConfig struct {
+	Enabled    bool `json:"enabled"`    // Keep a conversation on the endpoint that served it
+	TTL        int  `json:"ttl"`        // Seconds an idle conversation keeps its endpoint
+	MaxEntries int  `json:"maxEntries"` // Maximum conversations remembered, least recently used are dropped
+}
+
+// TransportConfig represents the connection pool settings for upstream requests
+type TransportConfig struct {
+	MaxIdleConns          int  `json:"maxIdleConns"`          // Idle connections kept per endpoint transport (0 means no limit)
+	MaxIdleConnsPerHost   int  `json:"maxIdleConnsPerHost"`   // Idle connections kept per upstream host
+	IdleConnTimeout       int  `json:"idleConnTimeout"`       // Seconds an idle connection is kept (0 means forever)
+	DialTimeout           int  `json:"dialTimeout"`           // Seconds to establish a TCP connection (0 means no timeout)
+	TLSHandshakeTimeout   int  `json:"tlsHandshakeTimeout"`   // Seconds for the TLS handshake (0 means no timeout)
+	ResponseHeaderTimeout int  `json:"responseHeaderTimeout"` // Seconds to wait for response headers (0 means no timeout)
+	KeepAlive             int  `json:"keepAlive"`             // Seconds between TCP keep-alive probes (0 means the system default)
+	DisableHTTP2          bool `json:"disableHTTP2"`          // Only use HTTP/1.1 upstream
+}
+
+// DefaultTransportConfig returns the default connection pool settings
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90,
+		DialTimeout:         30,
+		TLSHandshakeTimeout: 10,
+		KeepAlive:           30,
+	}
+}
+
+// RequestLogConfig represents the settings of the per-request log kept in the database
+type RequestLogConfig struct {
+	Enabled       bool `json:"enabled"`       // Record one row per proxied request
+	RetentionDays int  `json:"retentionDays"` // Days rows are kept, 0 keeps them forever
+}
+
+// DefaultRequestLogConfig returns the default request log settings: enabled, kept for 30 days
+func DefaultRequestLogConfig() RequestLogConfig {
+	return RequestLogConfig{
+		Enabled:       true,
+		RetentionDays: 30,
+	}
+}
+
+// Capture file formats
+const (
+	CaptureFormatJSONL = "jsonl" // All exchanges in one rotating JSON Lines file
+	CaptureFormatFiles = "files" // One JSON file per exchange
+)
+
+// CaptureConfig represents the settings of the full request/response capture
+type CaptureConfig struct {
+	Enabled        bool     `json:"enabled"`        // Capture every request, or only attempts on Endpoints
+	Endpoints      []string `json:"endpoints"`      // Endpoints whose attempts are captured; empty captures all
+	Format         string   `json:"format"`         // jsonl or files
+	Dir            string   `json:"dir"`            // Directory of the capture files ("" uses captures in the data directory)
+	MaxFileSizeMB  int      `json:"maxFileSizeMB"`  // Size at which the JSONL file rotates; with files, the size of the directory is kept under MaxFileSizeMB * MaxFiles
+	MaxFiles       int      `json:"maxFiles"`       // Rotated JSONL files kept
+	RedactHeaders  []string `json:"redactHeaders"`  // Headers redacted in addition to the credential headers
+	RedactPatterns []string `json:"redactPatterns"` // Regular expressions whose matches are redacted in URLs, headers and bodies
+}
+
+// DefaultCaptureConfig returns the default capture settings: disabled, JSONL rotated at 50 MB, 5 files kept
+func DefaultCaptureConfig() CaptureConfig {
+	return CaptureConfig{
+		Format:        CaptureFormatJSONL,
+		MaxFileSizeMB: 50,
+		MaxFiles:      5,
+	}
+}
+
+// ValidateCapture checks the capture settings
+func ValidateCapture(c CaptureConfig) error {
+	if c.Format != CaptureFormatJSONL && c.Format != CaptureFormatFiles {
+		return fmt.Errorf("capture format must be %s or %s", CaptureFormatJSONL, CaptureFormatFiles)
+	}
+	if c.MaxFileSizeMB <= 0 || c.MaxFiles <= 0 {
+		return fmt.Errorf("capture maxFileSizeMB and maxFiles must be positive")
+	}
+	for _, pattern := range c.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid capture redact pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// TracingConfig represents the OpenTelemetry trace export settings
+type TracingConfig struct {
+	Enabled     bool    `json:"enabled"`     // Export a trace for every proxy request
+	Endpoint    string  `json:"endpoint"`    // OTLP/HTTP traces URL of the collector
+	ServiceName string  `json:"serviceName"` // service.name of the exported spans
+	SampleRatio float64 `json:"sampleRatio"` // Share of requests traced, 0-1; requests with a traceparent follow its sampled flag
+}
+
+// TracingOverride holds trace export settings taken from the environment. Set fields take
+// precedence over the stored settings and are never saved.
+type TracingOverride struct {
+	Endpoint    string // Collector URL; setting it also enables the export
+	ServiceName string
+}
+
+// DefaultTracingConfig returns the default trace export settings: disabled, local collector
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		Endpoint:    "http://localhost:4318/v1/traces",
+		ServiceName: "ccnexus",
+		SampleRatio: 1,
+	}
+}
+
+// ValidateTracing checks the trace export settings
+func ValidateTracing(t TracingConfig) error {
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("tracing sampleRatio must be between 0 and 1")
+	}
+	if !t.Enabled {
+		return nil
+	}
+	u, err := url.Parse(t.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid tracing endpoint: %s", t.Endpoint)
+	}
+	return nil
+}
+
+// AdminConfig represents the authentication settings of the web admin API
+type AdminConfig struct {
+	PasswordHash   string   `json:"-"`              // Hash of the admin password, see HashPassword ("" disables password login)
+	TokenHash      string   `json:"-"`              // SHA-256 of the admin API token ("" disables token access)
+	SessionTTL     int      `json:"sessionTTL"`     // Hours a login session stays valid
+	AllowedOrigins []string `json:"allowedOrigins"` // Other origins allowed to call the admin API from a browser
+}
+
+// AdminOverride holds admin API settings taken from the environment. Set fields take
+// precedence over the stored settings and are never saved.
+type AdminOverride struct {
+	PasswordHash   string
+	TokenHash      string
+	AllowedOrigins []string
+}
+
+// DefaultAdminConfig returns the default admin API settings: no credentials, 24 hour sessions
+func DefaultAdminConfig() AdminConfig {
+	return AdminConfig{SessionTTL: 24}
+}
+
+// AuthRequired checks if the admin API asks for a password or token
+func (a AdminConfig) AuthRequired() bool {
+	return a.PasswordHash != "" || a.TokenHash != ""
+}
+
+// passwordIterations is the PBKDF2 iteration count for new admin password hashes
+const passwordIterations = 200000
+
+// HashPassword returns the stored form of an admin password: "pbkdf2-sha256$<iterations>$<salt>$<key>"
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// CheckPassword checks a password against a hash made by HashPassword
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// ValidateAdmin checks the admin API settings: a positive session lifetime and
+// origins of the form scheme://host[:port]
+func ValidateAdmin(a AdminConfig) error {
+	if a.SessionTTL <= 0 {
+		return fmt.Errorf("admin sessionTTL must be positive")
+	}
+	for _, origin := range a.AllowedOrigins {
+		if origin == "*" {
+			return fmt.Errorf("admin allowedOrigins must list origins, \"*\" is not allowed")
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("invalid admin allowed origin: %s", origin)
+		}
+	}
+	return nil
+}
+
 // Config represents the application configuration
 type Config struct {
 	Port                int           `json:"port"`
 	Endpoints           []Endpoint    `json:"endpoints"`
 	LogLevel            int           `json:"logLevel"`                      // 0=DEBUG, 1=INFO, 2=WARN, 3=ERROR
+	LogFormat           string        `json:"logFormat,omitempty"`           // Log output format: text (default) or json
 	Language            string        `json:"language"`                      // UI language: en, zh-CN
 	Theme               string        `json:"theme"`                         // UI theme: light, dark
 	ThemeAuto           bool          `json:"themeAuto"`                     // Auto switch theme based on time
@@ -64,6 +417,21 @@ type Config struct {
 	Update              *UpdateConfig   `json:"update,omitempty"`              // Update configuration
 	Terminal            *TerminalConfig `json:"terminal,omitempty"`            // Terminal launcher config
 	Proxy               *ProxyConfig    `json:"proxy,omitempty"`               // HTTP proxy config
+	LoadBalancing       string          `json:"loadBalancing,omitempty"`       // Endpoint selection strategy: failover, round_robin, weighted, least_inflight
+	CircuitBreaker      *CircuitBreakerConfig `json:"circuitBreaker,omitempty"` // Per-endpoint circuit breaker config
+	Routing             []RoutingRule         `json:"routing,omitempty"`        // Model routing rules, first match wins
+	Streaming           *StreamingConfig      `json:"streaming,omitempty"`      // Streaming response config
+	Hedging             *HedgingConfig        `json:"hedging,omitempty"`        // Request hedging config
+	Concurrency         *ConcurrencyConfig    `json:"concurrency,omitempty"`    // Concurrency wait queue config
+	Sticky              *StickyConfig         `json:"sticky,omitempty"`         // Conversation affinity config
+	Transport           *TransportConfig      `json:"transport,omitempty"`      // Upstream connection pool config
+	AccessTokens        []AccessToken         `json:"-"`                        // Client access tokens, kept in their own table
+	Admin               *AdminConfig          `json:"admin,omitempty"`          // Web admin API authentication
+	Tracing             *TracingConfig        `json:"tracing,omitempty"`        // OpenTelemetry trace export
+	RequestLog          *RequestLogConfig     `json:"requestLog,omitempty"`     // Per-request log retention
+	Capture             *CaptureConfig        `json:"capture,omitempty"`        // Full request/response capture
+	adminOverride       AdminOverride         // Admin settings from the environment, never saved
+	tracingOverride     TracingOverride       // Trace export settings from the environment, never saved
 	mu                  sync.RWMutex
 }
 
@@ -104,6 +472,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("no endpoints configured")
 	}
 
+	if c.LogFormat != "" && !IsValidLogFormat(c.LogFormat) {
+		return fmt.Errorf("invalid log format: %s", c.LogFormat)
+	}
+
 	for i, ep := range c.Endpoints {
 		if ep.APIUrl == "" {
 			return fmt.Errorf("endpoint %d: apiUrl is required", i+1)
@@ -121,8 +493,167 @@ func (c *Config) Validate() error {
 		if ep.Transformer != "claude" && ep.Model == "" {
 			return fmt.Errorf("endpoint %d (%s): model is required for transformer '%s'", i+1, ep.Name, ep.Transformer)
 		}
+		if ep.MaxConcurrent < 0 {
+			return fmt.Errorf("endpoint %d (%s): maxConcurrent must not be negative", i+1, ep.Name)
+		}
+		if ep.RPM < 0 || ep.TPM < 0 {
+			return fmt.Errorf("endpoint %d (%s): rpm and tpm must not be negative", i+1, ep.Name)
+		}
+		if ep.ConnectTimeout < 0 || ep.FirstByteTimeout < 0 || ep.TotalTimeout < 0 {
+			return fmt.Errorf("endpoint %d (%s): timeouts must not be negative", i+1, ep.Name)
+		}
+		if err := ValidateProxyURL(ep.ProxyURL); err != nil {
+			return fmt.Errorf("endpoint %d (%s): %w", i+1, ep.Name, err)
+		}
+		if err := ValidateAuthStyle(ep.AuthStyle, ep.AuthHeader); err != nil {
+			return fmt.Errorf("endpoint %d (%s): %w", i+1, ep.Name, err)
+		}
+		if err := ValidateHeaderRules(ep.Headers); err != nil {
+			return fmt.Errorf("endpoint %d (%s): %w", i+1, ep.Name, err)
+		}
+		if err := ValidateAPIKeys(ep.APIKeys, ep.KeyStrategy); err != nil {
+			return fmt.Errorf("endpoint %d (%s): %w", i+1, ep.Name, err)
+		}
+		if err := ValidateModelMap(ep.ModelMap); err != nil {
+			return fmt.Errorf("endpoint %d (%s): %w", i+1, ep.Name, err)
+		}
+	}
+
+	if err := ValidateRoutingRules(c.Routing); err != nil {
+		return err
+	}
+	if c.Admin != nil {
+		if err := ValidateAdmin(*c.Admin); err != nil {
+			return err
+		}
+	}
+	if c.Tracing != nil {
+		if err := ValidateTracing(*c.Tracing); err != nil {
+			return err
+		}
+	}
+	if c.RequestLog != nil && c.RequestLog.RetentionDays < 0 {
+		return fmt.Errorf("requestLog retentionDays must not be negative")
+	}
+	if c.Capture != nil {
+		if err := ValidateCapture(*c.Capture); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateRoutingRules checks that every rule has a valid pattern and a group
+func ValidateRoutingRules(rules []RoutingRule) error {
+	for i, rule := range rules {
+		if rule.Pattern == "" || rule.Group == "" {
+			return fmt.Errorf("routing rule %d: pattern and group are required", i+1)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("routing rule %d: invalid pattern '%s'", i+1, rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// ValidateModelMap checks that every mapping has a valid pattern and a target model
+func ValidateModelMap(mappings []ModelMapping) error {
+	for i, m := range mappings {
+		if m.Pattern == "" || m.Model == "" {
+			return fmt.Errorf("model mapping %d: pattern and model are required", i+1)
+		}
+		if _, err := path.Match(m.Pattern, ""); err != nil {
+			return fmt.Errorf("model mapping %d: invalid pattern '%s'", i+1, m.Pattern)
+		}
 	}
+	return nil
+}
 
+// ValidateProxyURL checks an endpoint proxy setting: empty, DirectProxy or an
+// http, https, socks5 or socks5h URL
+func ValidateProxyURL(proxyURL string) error {
+	if proxyURL == "" || proxyURL == DirectProxy {
+		return nil
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme: %s", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid proxy URL: missing host")
+	}
+	return nil
+}
+
+// ValidateAuthStyle checks an auth style and, for the header style, its header name
+func ValidateAuthStyle(style, header string) error {
+	switch style {
+	case "", AuthStyleBearer, AuthStyleAPIKey, AuthStyleGoogAPIKey, AuthStyleQuery:
+		return nil
+	case AuthStyleHeader:
+		if !validHeaderName(header) {
+			return fmt.Errorf("authHeader must be a valid header name for auth style '%s'", style)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid auth style '%s'", style)
+}
+
+// ValidateHeaderRules checks that every rule has a known action and a valid header name
+func ValidateHeaderRules(rules []HeaderRule) error {
+	for i, rule := range rules {
+		switch rule.Action {
+		case HeaderSet, HeaderAdd, HeaderRemove:
+		default:
+			return fmt.Errorf("header rule %d: action must be set, add or remove", i+1)
+		}
+		if !validHeaderName(rule.Name) {
+			return fmt.Errorf("header rule %d: invalid header name '%s'", i+1, rule.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateAPIKeys checks the additional keys of an endpoint and its key strategy
+func ValidateAPIKeys(keys []APIKey, strategy string) error {
+	switch strategy {
+	case "", KeyStrategyRoundRobin, KeyStrategyLeastRecent429:
+	default:
+		return fmt.Errorf("invalid key strategy '%s'", strategy)
+	}
+	for i, k := range keys {
+		if strings.TrimSpace(k.Key) == "" {
+			return fmt.Errorf("api key %d: key is required", i+1)
+		}
+	}
+	return nil
+}
+
+// validHeaderName checks a header name against the HTTP token characters
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c > 0x7e || c <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateTransport checks that no connection pool setting is negative
+func ValidateTransport(t TransportConfig) error {
+	if t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.IdleConnTimeout < 0 || t.DialTimeout < 0 ||
+		t.TLSHandshakeTimeout < 0 || t.ResponseHeaderTimeout < 0 || t.KeepAlive < 0 {
+		return fmt.Errorf("transport settings must not be negative")
+	}
 	return nil
 }
 
@@ -171,6 +702,28 @@ func (c *Config) UpdateLogLevel(level int) {
 	c.LogLevel = level
 }
 
+// GetLogFormat returns the configured log output format (thread-safe)
+func (c *Config) GetLogFormat() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.LogFormat == "" {
+		return LogFormatText
+	}
+	return c.LogFormat
+}
+
+// UpdateLogFormat updates the log output format (thread-safe)
+func (c *Config) UpdateLogFormat(format string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LogFormat = format
+}
+
+// IsValidLogFormat checks if a log output format is supported
+func IsValidLogFormat(format string) bool {
+	return format == LogFormatText || format == LogFormatJSON
+}
+
 // GetLanguage returns the configured language (thread-safe)
 func (c *Config) GetLanguage() string {
 	c.mu.RLock()
@@ -381,9 +934,369 @@ func (c *Config) UpdateProxy(proxy *ProxyConfig) {
 	c.Proxy = proxy
 }
 
+// GetLoadBalancing returns the endpoint selection strategy (thread-safe)
+// Returns "failover" when not set
+func (c *Config) GetLoadBalancing() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.LoadBalancing == "" {
+		return "failover"
+	}
+	return c.LoadBalancing
+}
+
+// UpdateLoadBalancing updates the endpoint selection strategy (thread-safe)
+func (c *Config) UpdateLoadBalancing(strategy string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LoadBalancing = strategy
+}
+
+// GetCircuitBreaker returns the circuit breaker configuration (thread-safe)
+func (c *Config) GetCircuitBreaker() *CircuitBreakerConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.CircuitBreaker == nil {
+		// Off unless configured, so endpoints are never skipped without the user opting in
+		return &CircuitBreakerConfig{
+			FailureThreshold: 0,
+			CooldownSeconds:  60,
+		}
+	}
+	return c.CircuitBreaker
+}
+
+// UpdateCircuitBreaker updates the circuit breaker configuration (thread-safe)
+func (c *Config) UpdateCircuitBreaker(breaker *CircuitBreakerConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.CircuitBreaker = breaker
+}
+
+// GetStreaming returns the streaming configuration (thread-safe)
+func (c *Config) GetStreaming() *StreamingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Streaming == nil {
+		return &StreamingConfig{
+			FirstEventTimeout: 0,
+		}
+	}
+	return c.Streaming
+}
+
+// UpdateStreaming updates the streaming configuration (thread-safe)
+func (c *Config) UpdateStreaming(streaming *StreamingConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Streaming = streaming
+}
+
+// GetHedging returns the request hedging configuration (thread-safe)
+func (c *Config) GetHedging() *HedgingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Hedging == nil {
+		return &HedgingConfig{}
+	}
+	return c.Hedging
+}
+
+// UpdateHedging updates the request hedging configuration (thread-safe)
+func (c *Config) UpdateHedging(hedging *HedgingConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Hedging = hedging
+}
+
+// GetConcurrency returns the concurrency wait queue configuration (thread-safe)
+func (c *Config) GetConcurrency() *ConcurrencyConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Concurrency == nil {
+		return &ConcurrencyConfig{
+			QueueSize:    20,
+			QueueTimeout: 30,
+		}
+	}
+	return c.Concurrency
+}
+
+// UpdateConcurrency updates the concurrency wait queue configuration (thread-safe)
+func (c *Config) UpdateConcurrency(concurrency *ConcurrencyConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Concurrency = concurrency
+}
+
+// GetSticky returns the conversation affinity configuration (thread-safe)
+func (c *Config) GetSticky() *StickyConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Sticky == nil {
+		return &StickyConfig{
+			Enabled:    false,
+			TTL:        3600,
+			MaxEntries: 10000,
+		}
+	}
+	return c.Sticky
+}
+
+// UpdateSticky updates the conversation affinity configuration (thread-safe)
+func (c *Config) UpdateSticky(sticky *StickyConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Sticky = sticky
+}
+
+// GetTransport returns the upstream connection pool configuration (thread-safe)
+func (c *Config) GetTransport() TransportConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Transport == nil {
+		return DefaultTransportConfig()
+	}
+	return *c.Transport
+}
+
+// UpdateTransport updates the upstream connection pool configuration (thread-safe)
+func (c *Config) UpdateTransport(transport *TransportConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Transport = transport
+}
+
+// GetRequestLog returns the request log configuration (thread-safe)
+func (c *Config) GetRequestLog() RequestLogConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.RequestLog == nil {
+		return DefaultRequestLogConfig()
+	}
+	return *c.RequestLog
+}
+
+// UpdateRequestLog updates the request log configuration (thread-safe)
+func (c *Config) UpdateRequestLog(requestLog *RequestLogConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RequestLog = requestLog
+}
+
+// GetCapture returns the capture configuration (thread-safe)
+func (c *Config) GetCapture() CaptureConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Capture == nil {
+		return DefaultCaptureConfig()
+	}
+	return *c.Capture
+}
+
+// UpdateCapture updates the capture configuration (thread-safe)
+func (c *Config) UpdateCapture(capture *CaptureConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Capture = capture
+}
+
+// GetTracing returns the trace export configuration with the environment overrides applied (thread-safe)
+func (c *Config) GetTracing() TracingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tracing := DefaultTracingConfig()
+	if c.Tracing != nil {
+		tracing = *c.Tracing
+	}
+	if c.tracingOverride.Endpoint != "" {
+		tracing.Endpoint = c.tracingOverride.Endpoint
+		tracing.Enabled = true
+	}
+	if c.tracingOverride.ServiceName != "" {
+		tracing.ServiceName = c.tracingOverride.ServiceName
+	}
+	return tracing
+}
+
+// UpdateTracing updates the trace export configuration (thread-safe). Settings overridden
+// by the environment keep their stored value.
+func (c *Config) UpdateTracing(tracing *TracingConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored := DefaultTracingConfig()
+	if c.Tracing != nil {
+		stored = *c.Tracing
+	}
+	if c.tracingOverride.Endpoint != "" {
+		tracing.Endpoint = stored.Endpoint
+		tracing.Enabled = stored.Enabled
+	}
+	if c.tracingOverride.ServiceName != "" {
+		tracing.ServiceName = stored.ServiceName
+	}
+	c.Tracing = tracing
+}
+
+// GetTracingOverride returns the trace export settings taken from the environment (thread-safe)
+func (c *Config) GetTracingOverride() TracingOverride {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tracingOverride
+}
+
+// SetTracingOverride sets the trace export settings taken from the environment (thread-safe)
+func (c *Config) SetTracingOverride(override TracingOverride) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracingOverride = override
+}
+
+// GetAdmin returns the web admin API settings with the environment overrides applied (thread-safe)
+func (c *Config) GetAdmin() AdminConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	admin := DefaultAdminConfig()
+	if c.Admin != nil {
+		admin = *c.Admin
+		admin.AllowedOrigins = append([]string(nil), c.Admin.AllowedOrigins...)
+	}
+	if c.adminOverride.PasswordHash != "" {
+		admin.PasswordHash = c.adminOverride.PasswordHash
+	}
+	if c.adminOverride.TokenHash != "" {
+		admin.TokenHash = c.adminOverride.TokenHash
+	}
+	if c.adminOverride.AllowedOrigins != nil {
+		admin.AllowedOrigins = append([]string(nil), c.adminOverride.AllowedOrigins...)
+	}
+	return admin
+}
+
+// UpdateAdmin updates the web admin API settings (thread-safe). Settings overridden by the
+// environment keep their stored value, so the override is not saved with the rest.
+func (c *Config) UpdateAdmin(admin *AdminConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored := DefaultAdminConfig()
+	if c.Admin != nil {
+		stored = *c.Admin
+	}
+	if c.adminOverride.PasswordHash != "" {
+		admin.PasswordHash = stored.PasswordHash
+	}
+	if c.adminOverride.TokenHash != "" {
+		admin.TokenHash = stored.TokenHash
+	}
+	if c.adminOverride.AllowedOrigins != nil {
+		admin.AllowedOrigins = stored.AllowedOrigins
+	}
+	c.Admin = admin
+}
+
+// GetAdminOverride returns the admin settings taken from the environment (thread-safe)
+func (c *Config) GetAdminOverride() AdminOverride {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.adminOverride
+}
+
+// SetAdminOverride sets the admin settings taken from the environment (thread-safe)
+func (c *Config) SetAdminOverride(override AdminOverride) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.adminOverride = override
+}
+
+// InheritOverrides applies the environment overrides of prev to a configuration reloaded from storage
+func (c *Config) InheritOverrides(prev *Config) {
+	c.SetAdminOverride(prev.GetAdminOverride())
+	c.SetTracingOverride(prev.GetTracingOverride())
+}
+
+// GetRouting returns a copy of the model routing rules (thread-safe)
+func (c *Config) GetRouting() []RoutingRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rules := make([]RoutingRule, len(c.Routing))
+	copy(rules, c.Routing)
+	return rules
+}
+
+// UpdateRouting updates the model routing rules (thread-safe)
+func (c *Config) UpdateRouting(rules []RoutingRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Routing = rules
+}
+
+// AccessToken is a token issued to a client of the proxy. Only its SHA-256 hash is stored.
+type AccessToken struct {
+	ID        int64
+	Name      string // Client identity
+	TokenHash string
+	Endpoints []string // Endpoints the client may use, all if empty
+	ExpiresAt *time.Time
+	Revoked   bool
+	Quota     ClientQuota
+}
+
+// ClientQuota limits the usage of one client; 0 means unlimited.
+// Tokens count input plus output tokens.
+type ClientQuota struct {
+	DailyRequests   int `json:"dailyRequests,omitempty"`
+	DailyTokens     int `json:"dailyTokens,omitempty"`
+	MonthlyRequests int `json:"monthlyRequests,omitempty"`
+	MonthlyTokens   int `json:"monthlyTokens,omitempty"`
+}
+
+// ValidateClientQuota checks that no quota is negative
+func ValidateClientQuota(q ClientQuota) error {
+	if q.DailyRequests < 0 || q.DailyTokens < 0 || q.MonthlyRequests < 0 || q.MonthlyTokens < 0 {
+		return fmt.Errorf("quotas must not be negative")
+	}
+	return nil
+}
+
+// HashToken returns the stored form of an access token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired checks if an access token is past its expiry
+func (t AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// AllowsEndpoint checks if an access token may use an endpoint
+func (t AccessToken) AllowsEndpoint(name string) bool {
+	if len(t.Endpoints) == 0 {
+		return true
+	}
+	for _, allowed := range t.Endpoints {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAccessTokens returns the issued client access tokens, revoked ones included
+func (c *Config) GetAccessTokens() []AccessToken {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tokens := make([]AccessToken, len(c.AccessTokens))
+	copy(tokens, c.AccessTokens)
+	return tokens
+}
+
 // StorageAdapter defines the interface needed for loading/saving config
 type StorageAdapter interface {
 	GetEndpoints() ([]StorageEndpoint, error)
+	GetAccessTokens() ([]AccessToken, error)
 	SaveEndpoint(ep *StorageEndpoint) error
 	UpdateEndpoint(ep *StorageEndpoint) error
 	DeleteEndpoint(name string) error
@@ -393,14 +1306,29 @@ type StorageAdapter interface {
 
 // StorageEndpoint represents an endpoint in storage
 type StorageEndpoint struct {
-	Name        string
-	APIUrl      string
-	APIKey      string
-	Enabled     bool
-	Transformer string
-	Model       string
-	Remark      string
-	SortOrder   int
+	Name             string
+	APIUrl           string
+	APIKey           string
+	Enabled          bool
+	Transformer      string
+	Model            string
+	Remark           string
+	SortOrder        int
+	Weight           int
+	Group            string
+	ModelMap         []ModelMapping
+	MaxConcurrent    int
+	RPM              int
+	TPM              int
+	ProxyURL         string
+	ConnectTimeout   int
+	FirstByteTimeout int
+	TotalTimeout     int
+	AuthStyle        string
+	AuthHeader       string
+	Headers          []HeaderRule
+	APIKeys          []APIKey
+	KeyStrategy      string
 }
 
 // LoadFromStorage loads configuration from SQLite storage
@@ -417,13 +1345,28 @@ func LoadFromStorage(storage StorageAdapter) (*Config, error) {
 
 	for _, ep := range endpoints {
 		endpoint := Endpoint{
-			Name:        ep.Name,
-			APIUrl:      ep.APIUrl,
-			APIKey:      ep.APIKey,
-			Enabled:     ep.Enabled,
-			Transformer: ep.Transformer,
-			Model:       ep.Model,
-			Remark:      ep.Remark,
+			Name:             ep.Name,
+			APIUrl:           ep.APIUrl,
+			APIKey:           ep.APIKey,
+			Enabled:          ep.Enabled,
+			Transformer:      ep.Transformer,
+			Model:            ep.Model,
+			Remark:           ep.Remark,
+			Weight:           ep.Weight,
+			Group:            ep.Group,
+			ModelMap:         ep.ModelMap,
+			MaxConcurrent:    ep.MaxConcurrent,
+			RPM:              ep.RPM,
+			TPM:              ep.TPM,
+			ProxyURL:         ep.ProxyURL,
+			ConnectTimeout:   ep.ConnectTimeout,
+			FirstByteTimeout: ep.FirstByteTimeout,
+			TotalTimeout:     ep.TotalTimeout,
+			AuthStyle:        ep.AuthStyle,
+			AuthHeader:       ep.AuthHeader,
+			Headers:          ep.Headers,
+			APIKeys:          ep.APIKeys,
+			KeyStrategy:      ep.KeyStrategy,
 		}
 		if endpoint.Transformer == "" {
 			endpoint.Transformer = "claude"
@@ -431,6 +1374,13 @@ func LoadFromStorage(storage StorageAdapter) (*Config, error) {
 		config.Endpoints = append(config.Endpoints, endpoint)
 	}
 
+	// Load access tokens
+	tokens, err := storage.GetAccessTokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load access tokens: %w", err)
+	}
+	config.AccessTokens = tokens
+
 	// Load app config
 	if portStr, err := storage.GetConfig("port"); err == nil && portStr != "" {
 		if port, err := strconv.Atoi(portStr); err == nil {
@@ -447,6 +1397,10 @@ func LoadFromStorage(storage StorageAdapter) (*Config, error) {
 		}
 	}
 
+	if logFormat, err := storage.GetConfig("logFormat"); err == nil && IsValidLogFormat(logFormat) {
+		config.LogFormat = logFormat
+	}
+
 	if lang, err := storage.GetConfig("language"); err == nil {
 		config.Language = lang
 	}
@@ -510,6 +1464,138 @@ func LoadFromStorage(storage StorageAdapter) (*Config, error) {
 		config.AutoDarkTheme = "dark"
 	}
 
+	// Load load balancing strategy
+	if strategy, err := storage.GetConfig("loadBalancing"); err == nil && strategy != "" {
+		config.LoadBalancing = strategy
+	}
+
+	// Load circuit breaker config if exists
+	if thresholdStr, err := storage.GetConfig("breaker_failureThreshold"); err == nil && thresholdStr != "" {
+		config.CircuitBreaker = &CircuitBreakerConfig{
+			FailureThreshold: 0,
+			CooldownSeconds:  60,
+		}
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil {
+			config.CircuitBreaker.FailureThreshold = threshold
+		}
+		if cooldownStr, err := storage.GetConfig("breaker_cooldownSeconds"); err == nil && cooldownStr != "" {
+			if cooldown, err := strconv.Atoi(cooldownStr); err == nil {
+				config.CircuitBreaker.CooldownSeconds = cooldown
+			}
+		}
+	}
+
+	// Load streaming config if exists
+	if timeoutStr, err := storage.GetConfig("streaming_firstEventTimeout"); err == nil && timeoutStr != "" {
+		if timeout, err := strconv.Atoi(timeoutStr); err == nil {
+			config.Streaming = &StreamingConfig{FirstEventTimeout: timeout}
+		}
+	}
+
+	// Load hedging config if exists
+	if delayStr, err := storage.GetConfig("hedging_delayMs"); err == nil && delayStr != "" {
+		if delay, err := strconv.Atoi(delayStr); err == nil {
+			config.Hedging = &HedgingConfig{DelayMs: delay}
+		}
+	}
+
+	// Load concurrency config if exists
+	if queueSizeStr, err := storage.GetConfig("concurrency_queueSize"); err == nil && queueSizeStr != "" {
+		config.Concurrency = &ConcurrencyConfig{
+			QueueSize:    20,
+			QueueTimeout: 30,
+		}
+		if queueSize, err := strconv.Atoi(queueSizeStr); err == nil {
+			config.Concurrency.QueueSize = queueSize
+		}
+		if timeoutStr, err := storage.GetConfig("concurrency_queueTimeout"); err == nil && timeoutStr != "" {
+			if timeout, err := strconv.Atoi(timeoutStr); err == nil {
+				config.Concurrency.QueueTimeout = timeout
+			}
+		}
+	}
+
+	// Load sticky routing config if exists
+	if enabledStr, err := storage.GetConfig("sticky_enabled"); err == nil && enabledStr != "" {
+		config.Sticky = &StickyConfig{
+			Enabled:    enabledStr == "true",
+			TTL:        3600,
+			MaxEntries: 10000,
+		}
+		if ttlStr, err := storage.GetConfig("sticky_ttl"); err == nil && ttlStr != "" {
+			if ttl, err := strconv.Atoi(ttlStr); err == nil {
+				config.Sticky.TTL = ttl
+			}
+		}
+		if maxStr, err := storage.GetConfig("sticky_maxEntries"); err == nil && maxStr != "" {
+			if maxEntries, err := strconv.Atoi(maxStr); err == nil {
+				config.Sticky.MaxEntries = maxEntries
+			}
+		}
+	}
+
+	// Load transport config if exists
+	if transportStr, err := storage.GetConfig("transport"); err == nil && transportStr != "" {
+		transport := DefaultTransportConfig()
+		if err := json.Unmarshal([]byte(transportStr), &transport); err == nil {
+			config.Transport = &transport
+		}
+	}
+
+	// Load request log config if exists
+	if requestLogStr, err := storage.GetConfig("requestLog"); err == nil && requestLogStr != "" {
+		requestLog := DefaultRequestLogConfig()
+		if err := json.Unmarshal([]byte(requestLogStr), &requestLog); err == nil {
+			config.RequestLog = &requestLog
+		}
+	}
+
+	// Load capture config if exists
+	if captureStr, err := storage.GetConfig("capture"); err == nil && captureStr != "" {
+		capture := DefaultCaptureConfig()
+		if err := json.Unmarshal([]byte(captureStr), &capture); err == nil {
+			config.Capture = &capture
+		}
+	}
+
+	// Load tracing config if exists
+	if tracingStr, err := storage.GetConfig("tracing"); err == nil && tracingStr != "" {
+		tracing := DefaultTracingConfig()
+		if err := json.Unmarshal([]byte(tracingStr), &tracing); err == nil {
+			config.Tracing = &tracing
+		}
+	}
+
+	// Load admin API settings if exists
+	if adminStr, err := storage.GetConfig("admin"); err == nil && adminStr != "" {
+		admin := DefaultAdminConfig()
+		if err := json.Unmarshal([]byte(adminStr), &admin); err == nil {
+			config.Admin = &admin
+		}
+	}
+	if hash, err := storage.GetConfig("admin_passwordHash"); err == nil && hash != "" {
+		if config.Admin == nil {
+			admin := DefaultAdminConfig()
+			config.Admin = &admin
+		}
+		config.Admin.PasswordHash = hash
+	}
+	if hash, err := storage.GetConfig("admin_tokenHash"); err == nil && hash != "" {
+		if config.Admin == nil {
+			admin := DefaultAdminConfig()
+			config.Admin = &admin
+		}
+		config.Admin.TokenHash = hash
+	}
+
+	// Load model routing rules
+	if rulesStr, err := storage.GetConfig("routing_rules"); err == nil && rulesStr != "" {
+		var rules []RoutingRule
+		if err := json.Unmarshal([]byte(rulesStr), &rules); err == nil {
+			config.Routing = rules
+		}
+	}
+
 	// Load WebDAV config if exists
 	if url, err := storage.GetConfig("webdav_url"); err == nil && url != "" {
 		username, _ := storage.GetConfig("webdav_username")
@@ -588,14 +1674,29 @@ func (c *Config) SaveToStorage(storage StorageAdapter) error {
 	// Save/update endpoints
 	for i, ep := range c.Endpoints {
 		endpoint := &StorageEndpoint{
-			Name:        ep.Name,
-			APIUrl:      ep.APIUrl,
-			APIKey:      ep.APIKey,
-			Enabled:     ep.Enabled,
-			Transformer: ep.Transformer,
-			Model:       ep.Model,
-			Remark:      ep.Remark,
-			SortOrder:   i, // Use array index as sort order
+			Name:             ep.Name,
+			APIUrl:           ep.APIUrl,
+			APIKey:           ep.APIKey,
+			Enabled:          ep.Enabled,
+			Transformer:      ep.Transformer,
+			Model:            ep.Model,
+			Remark:           ep.Remark,
+			SortOrder:        i, // Use array index as sort order
+			Weight:           ep.Weight,
+			Group:            ep.Group,
+			ModelMap:         ep.ModelMap,
+			MaxConcurrent:    ep.MaxConcurrent,
+			RPM:              ep.RPM,
+			TPM:              ep.TPM,
+			ProxyURL:         ep.ProxyURL,
+			ConnectTimeout:   ep.ConnectTimeout,
+			FirstByteTimeout: ep.FirstByteTimeout,
+			TotalTimeout:     ep.TotalTimeout,
+			AuthStyle:        ep.AuthStyle,
+			AuthHeader:       ep.AuthHeader,
+			Headers:          ep.Headers,
+			APIKeys:          ep.APIKeys,
+			KeyStrategy:      ep.KeyStrategy,
 		}
 
 		if existingNames[ep.Name] {
@@ -620,6 +1721,9 @@ func (c *Config) SaveToStorage(storage StorageAdapter) error {
 	// Save app config
 	storage.SetConfig("port", strconv.Itoa(c.Port))
 	storage.SetConfig("logLevel", strconv.Itoa(c.LogLevel))
+	if c.LogFormat != "" {
+		storage.SetConfig("logFormat", c.LogFormat)
+	}
 	storage.SetConfig("language", c.Language)
 	storage.SetConfig("theme", c.Theme)
 	storage.SetConfig("themeAuto", strconv.FormatBool(c.ThemeAuto))
@@ -628,6 +1732,78 @@ func (c *Config) SaveToStorage(storage StorageAdapter) error {
 	storage.SetConfig("windowWidth", strconv.Itoa(c.WindowWidth))
 	storage.SetConfig("windowHeight", strconv.Itoa(c.WindowHeight))
 	storage.SetConfig("closeWindowBehavior", c.CloseWindowBehavior)
+	storage.SetConfig("loadBalancing", c.LoadBalancing)
+
+	// Save circuit breaker config
+	if c.CircuitBreaker != nil {
+		storage.SetConfig("breaker_failureThreshold", strconv.Itoa(c.CircuitBreaker.FailureThreshold))
+		storage.SetConfig("breaker_cooldownSeconds", strconv.Itoa(c.CircuitBreaker.CooldownSeconds))
+	}
+
+	// Save streaming config
+	if c.Streaming != nil {
+		storage.SetConfig("streaming_firstEventTimeout", strconv.Itoa(c.Streaming.FirstEventTimeout))
+	}
+
+	// Save hedging config
+	if c.Hedging != nil {
+		storage.SetConfig("hedging_delayMs", strconv.Itoa(c.Hedging.DelayMs))
+	}
+
+	// Save concurrency config
+	if c.Concurrency != nil {
+		storage.SetConfig("concurrency_queueSize", strconv.Itoa(c.Concurrency.QueueSize))
+		storage.SetConfig("concurrency_queueTimeout", strconv.Itoa(c.Concurrency.QueueTimeout))
+	}
+
+	// Save sticky routing config
+	if c.Sticky != nil {
+		storage.SetConfig("sticky_enabled", strconv.FormatBool(c.Sticky.Enabled))
+		storage.SetConfig("sticky_ttl", strconv.Itoa(c.Sticky.TTL))
+		storage.SetConfig("sticky_maxEntries", strconv.Itoa(c.Sticky.MaxEntries))
+	}
+
+	// Save transport config
+	if c.Transport != nil {
+		if transportJSON, err := json.Marshal(c.Transport); err == nil {
+			storage.SetConfig("transport", string(transportJSON))
+		}
+	}
+
+	// Save request log config
+	if c.RequestLog != nil {
+		if requestLogJSON, err := json.Marshal(c.RequestLog); err == nil {
+			storage.SetConfig("requestLog", string(requestLogJSON))
+		}
+	}
+
+	// Save capture config
+	if c.Capture != nil {
+		if captureJSON, err := json.Marshal(c.Capture); err == nil {
+			storage.SetConfig("capture", string(captureJSON))
+		}
+	}
+
+	// Save tracing config
+	if c.Tracing != nil {
+		if tracingJSON, err := json.Marshal(c.Tracing); err == nil {
+			storage.SetConfig("tracing", string(tracingJSON))
+		}
+	}
+
+	// Save admin API settings, the credential hashes under their own keys
+	if c.Admin != nil {
+		if adminJSON, err := json.Marshal(c.Admin); err == nil {
+			storage.SetConfig("admin", string(adminJSON))
+		}
+		storage.SetConfig("admin_passwordHash", c.Admin.PasswordHash)
+		storage.SetConfig("admin_tokenHash", c.Admin.TokenHash)
+	}
+
+	// Save model routing rules
+	if rulesJSON, err := json.Marshal(c.Routing); err == nil {
+		storage.SetConfig("routing_rules", string(rulesJSON))
+	}
 
 	// Save WebDAV config
 	if c.WebDAV != nil {
@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -47,6 +51,25 @@ func (l LogLevel) Icon() string {
 	}
 }
 
+// Format selects how log lines are written to the console and the debug file
+type Format int
+
+const (
+	FormatText Format = iota // Icon, level and message, followed by key=value fields
+	FormatJSON               // One JSON object per line
+)
+
+// ParseFormat returns the format named "text" or "json"; anything else is text
+func ParseFormat(name string) Format {
+	if strings.EqualFold(strings.TrimSpace(name), "json") {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// Fields are structured key/value pairs attached to a log entry
+type Fields map[string]interface{}
+
 // LogEntry represents a single log entry
 type LogEntry struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -54,6 +77,7 @@ type LogEntry struct {
 	Message   string    `json:"message"`
 	Icon      string    `json:"icon"`
 	LevelStr  string    `json:"levelStr"`
+	Fields    Fields    `json:"fields,omitempty"`
 }
 
 // Logger manages application logs
@@ -63,6 +87,7 @@ type Logger struct {
 	maxSize      int
 	minLevel     LogLevel // Minimum level to record
 	consoleLevel LogLevel // Minimum level to print to console
+	format       Format   // Output format of the console and debug file
 	debugFile    *os.File // Debug log file (only in debug mode)
 	debugMu      sync.Mutex
 }
@@ -99,6 +124,13 @@ func (l *Logger) SetConsoleLevel(level LogLevel) {
 	l.consoleLevel = level
 }
 
+// SetFormat sets the output format of the console and the debug file
+func (l *Logger) SetFormat(format Format) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+}
+
 // GetMinLevel returns the current minimum log level
 func (l *Logger) GetMinLevel() LogLevel {
 	l.mu.RLock()
@@ -108,6 +140,11 @@ func (l *Logger) GetMinLevel() LogLevel {
 
 // Log adds a new log entry
 func (l *Logger) Log(level LogLevel, format string, args ...interface{}) {
+	l.log(level, nil, format, args...)
+}
+
+// log adds a new log entry with structured fields
+func (l *Logger) log(level LogLevel, fields Fields, format string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -123,6 +160,7 @@ func (l *Logger) Log(level LogLevel, format string, args ...interface{}) {
 		Message:   message,
 		Icon:      level.Icon(),
 		LevelStr:  level.String(),
+		Fields:    fields,
 	}
 
 	// Add to memory
@@ -135,8 +173,62 @@ func (l *Logger) Log(level LogLevel, format string, args ...interface{}) {
 
 	// Print to console only if level >= consoleLevel
 	if level >= l.consoleLevel {
-		fmt.Printf("%s [%s] %s\n", entry.Icon, entry.LevelStr, entry.Message)
+		if l.format == FormatJSON {
+			fmt.Println(formatJSON(entry.Timestamp, entry.LevelStr, entry.Message, fields))
+		} else {
+			fmt.Printf("%s [%s] %s%s\n", entry.Icon, entry.LevelStr, entry.Message, formatFields(fields))
+		}
+	}
+}
+
+// formatJSON renders a log line as a JSON object: time, level and msg, then the fields sorted by key
+func formatJSON(timestamp time.Time, level, message string, fields Fields) string {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, timestamp.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, strings.ToLower(level))
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, message)
+	for _, key := range sortedFieldKeys(fields) {
+		if key == "time" || key == "level" || key == "msg" {
+			continue
+		}
+		buf.WriteByte(',')
+		writeJSON(&buf, key)
+		buf.WriteByte(':')
+		writeJSON(&buf, fields[key])
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// writeJSON appends a JSON value, falling back to its string form if it cannot be encoded
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
+
+// formatFields renders fields as " key=value" pairs sorted by key
+func formatFields(fields Fields) string {
+	var b strings.Builder
+	for _, key := range sortedFieldKeys(fields) {
+		fmt.Fprintf(&b, " %s=%v", key, fields[key])
 	}
+	return b.String()
+}
+
+// sortedFieldKeys returns the keys of fields in a stable order
+func sortedFieldKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // GetLogs returns all log entries
@@ -164,6 +256,20 @@ func (l *Logger) GetLogsByLevel(minLevel LogLevel) []LogEntry {
 	return result
 }
 
+// GetLogsByRequestID returns the logs of one proxy request
+func (l *Logger) GetLogsByRequestID(requestID string) []LogEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]LogEntry, 0)
+	for _, entry := range l.entries {
+		if id, ok := entry.Fields["request_id"].(string); ok && id == requestID {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
 // Clear removes all log entries
 func (l *Logger) Clear() {
 	l.mu.Lock()
@@ -208,6 +314,15 @@ func (l *Logger) EnableDebugFile(filepath string) error {
 
 // DebugLog writes to debug.log file (bypasses log level)
 func (l *Logger) DebugLog(format string, args ...interface{}) {
+	l.debugLog(nil, format, args...)
+}
+
+// debugLog writes to debug.log file with structured fields
+func (l *Logger) debugLog(fields Fields, format string, args ...interface{}) {
+	l.mu.RLock()
+	jsonFormat := l.format == FormatJSON
+	l.mu.RUnlock()
+
 	l.debugMu.Lock()
 	defer l.debugMu.Unlock()
 
@@ -216,8 +331,13 @@ func (l *Logger) DebugLog(format string, args ...interface{}) {
 	}
 
 	message := fmt.Sprintf(format, args...)
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	fmt.Fprintf(l.debugFile, "[%s] %s\n", timestamp, message)
+	now := time.Now()
+	if jsonFormat {
+		fmt.Fprintln(l.debugFile, formatJSON(now, DEBUG.String(), message, fields))
+		return
+	}
+	timestamp := now.Format("2006-01-02 15:04:05.000")
+	fmt.Fprintf(l.debugFile, "[%s] %s%s\n", timestamp, message, formatFields(fields))
 }
 
 // Close closes the debug log file
@@ -234,3 +354,55 @@ func (l *Logger) Close() {
 func DebugLog(format string, args ...interface{}) {
 	GetLogger().DebugLog(format, args...)
 }
+
+// FieldLogger logs entries that all carry the same structured fields. A nil FieldLogger
+// logs without fields.
+type FieldLogger struct {
+	fields Fields
+}
+
+// getFields returns the fields of f, nil for a nil logger
+func (f *FieldLogger) getFields() Fields {
+	if f == nil {
+		return nil
+	}
+	return f.fields
+}
+
+// WithFields returns a logger that attaches fields to every entry
+func WithFields(fields Fields) *FieldLogger {
+	return (&FieldLogger{}).WithFields(fields)
+}
+
+// WithFields returns a logger with the fields of f plus the given ones
+func (f *FieldLogger) WithFields(fields Fields) *FieldLogger {
+	merged := make(Fields, len(f.getFields())+len(fields))
+	for k, v := range f.getFields() {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &FieldLogger{fields: merged}
+}
+
+func (f *FieldLogger) Debug(format string, args ...interface{}) {
+	GetLogger().log(DEBUG, f.getFields(), format, args...)
+}
+
+func (f *FieldLogger) Info(format string, args ...interface{}) {
+	GetLogger().log(INFO, f.getFields(), format, args...)
+}
+
+func (f *FieldLogger) Warn(format string, args ...interface{}) {
+	GetLogger().log(WARN, f.getFields(), format, args...)
+}
+
+func (f *FieldLogger) Error(format string, args ...interface{}) {
+	GetLogger().log(ERROR, f.getFields(), format, args...)
+}
+
+// DebugLog writes to debug.log file with the logger's fields
+func (f *FieldLogger) DebugLog(format string, args ...interface{}) {
+	GetLogger().debugLog(f.getFields(), format, args...)
+}
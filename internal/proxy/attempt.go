@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/tracing"
+)
+
+// proxyRequest is the state of one client request while handleProxy tries it on endpoints
+type proxyRequest struct {
+	p            *Proxy
+	w            http.ResponseWriter
+	r            *http.Request
+	clientFormat ClientFormat
+	reqID        string
+	log          *logger.FieldLogger
+	span         *tracing.Span       // request span, nil when not traced
+	token        *config.AccessToken // client token, nil without access tokens
+	rec          *RequestRecord      // request log row, written when the request is done
+	body         []byte              // client request body
+	model        string              // model the client asked for
+	stream       bool                // the client asked for a stream
+	convKey      string              // conversation the request belongs to, "" if unknown
+	strategy     Strategy
+
+	captureAll   bool                // capture every attempt, not just those of captured endpoints
+	captures     []*capture.Exchange // captures of the attempts, recorded when the request is done
+	clientResult ClientUsage         // errors and tokens of the request, recorded when it is done
+
+	attempts       map[string]int  // attempts by endpoint
+	totalAttempts  int             // attempts on all endpoints
+	exhausted      map[string]bool // endpoints given up on
+	reservedTokens int             // input tokens reserved against TPM limits per attempt
+	primaryName    string          // endpoint of the attempt being hedged
+}
+
+// attemptFailed records a failed attempt and gives up on the endpoint after two tries
+// or as soon as its circuit opens
+func (pr *proxyRequest) attemptFailed(endpoint config.Endpoint, key string) {
+	p := pr.p
+	p.stats.RecordError(endpoint.Name)
+	p.metrics.recordError(endpoint.Name)
+	p.stats.RecordKeyUsage(endpoint.Name, key, KeyUsage{Errors: 1})
+	p.markRequestInactive(endpoint.Name)
+	p.limiterRefund(endpoint, pr.reservedTokens)
+	p.recordBreakerFailure(endpoint.Name)
+	if pr.attempts[endpoint.Name] >= 2 || !p.breakerAvailable(endpoint.Name) {
+		pr.exhausted[endpoint.Name] = true
+		pr.strategy.OnFailure(endpoint)
+	}
+}
+
+// eligible returns the endpoints a new attempt may use right away, optionally leaving one
+// out, and the ones that are only held back by their concurrency limit.
+// Endpoints that used up their RPM/TPM budget or all their API keys are skipped like cooling ones.
+func (pr *proxyRequest) eligible(exclude string) ([]config.Endpoint, []config.Endpoint, []config.Endpoint) {
+	p := pr.p
+	routed, _ := p.routeEndpoints(pr.model, pr.token)
+	candidates := make([]config.Endpoint, 0, len(routed))
+	var busy []config.Endpoint
+	for _, ep := range routed {
+		if ep.Name == exclude || pr.exhausted[ep.Name] || !p.breakerAvailable(ep.Name) || p.cooldownRemaining(ep.Name) > 0 ||
+			p.limiterWait(ep, pr.reservedTokens) > 0 || !p.hasUsableKey(ep) {
+			continue
+		}
+		if p.hasFreeSlot(ep) {
+			candidates = append(candidates, ep)
+		} else {
+			busy = append(busy, ep)
+		}
+	}
+	return candidates, busy, routed
+}
+
+// startAttempt claims an endpoint for an attempt and prepares the upstream request.
+// With hasSlot the caller already holds a concurrency slot of the endpoint.
+func (pr *proxyRequest) startAttempt(endpoint config.Endpoint, hasSlot bool) (*upstreamAttempt, bool) {
+	p := pr.p
+
+	// Concurrent requests may have rate limited the remaining keys since eligible ran
+	key, ok := p.selectKey(endpoint)
+	if !ok {
+		if hasSlot {
+			p.markRequestInactive(endpoint.Name)
+		}
+		return nil, false
+	}
+
+	// Saturated endpoints are skipped here; the request spills over to the next one
+	if !hasSlot && !p.tryAcquireSlot(endpoint) {
+		return nil, false
+	}
+
+	// Another request may have claimed the half-open probe in the meantime
+	if !p.breakerAcquire(endpoint.Name) {
+		p.markRequestInactive(endpoint.Name)
+		pr.exhausted[endpoint.Name] = true
+		return nil, false
+	}
+
+	// Concurrent requests may have used up the RPM/TPM budget since eligible ran
+	if !p.limiterReserve(endpoint, pr.reservedTokens) {
+		p.markRequestInactive(endpoint.Name)
+		p.releaseBreaker(endpoint.Name)
+		return nil, false
+	}
+
+	pr.attempts[endpoint.Name]++
+	pr.totalAttempts++
+	p.stats.RecordRequest(endpoint.Name)
+	p.stats.RecordKeyUsage(endpoint.Name, key, KeyUsage{Requests: 1})
+	pr.span.SetAttribute("ccnexus.attempts", pr.totalAttempts)
+	pr.rec.Attempts = pr.totalAttempts
+
+	actx, span := tracing.Start(pr.r.Context(), "upstream_attempt", tracing.KindClient)
+	span.SetAttribute("ccnexus.endpoint", endpoint.Name)
+	span.SetAttribute("ccnexus.api_key", keyLabel(endpoint, key))
+	attempt, err := prepareAttempt(pr.r.WithContext(actx), pr.clientFormat, endpoint, key, pr.model, pr.body)
+	if err != nil {
+		span.SetError(err.Error())
+		span.End()
+		pr.log.WithFields(logger.Fields{"endpoint": endpoint.Name, "attempt": pr.totalAttempts}).Error("[%s] %v", endpoint.Name, err)
+		pr.rec.Endpoint, pr.rec.Error = endpoint.Name, err.Error()
+		pr.attemptFailed(endpoint, key)
+		return nil, false
+	}
+	attempt.reservedTokens = pr.reservedTokens
+	span.SetAttribute("ccnexus.transformer", attempt.transformerName)
+	span.Inject(attempt.proxyReq.Header)
+	attempt.span = span
+	attempt.proxyReq.Header.Set(requestIDHeader, pr.reqID)
+	attempt.log = pr.log.WithFields(logger.Fields{
+		"endpoint":    endpoint.Name,
+		"transformer": attempt.transformerName,
+		"attempt":     pr.totalAttempts,
+	})
+	if pr.captureAll || p.capture.CapturesEndpoint(endpoint.Name) {
+		attempt.capture = &capture.Exchange{
+			Time:            attempt.started,
+			RequestID:       pr.reqID,
+			Attempt:         pr.totalAttempts,
+			Endpoint:        endpoint.Name,
+			Transformer:     attempt.transformerName,
+			ClientFormat:    string(pr.clientFormat),
+			ClientRequest:   capture.NewRequest(pr.r, pr.body),
+			UpstreamRequest: capture.NewRequest(attempt.proxyReq, attempt.body),
+		}
+		pr.captures = append(pr.captures, attempt.capture)
+	}
+	return attempt, true
+}
+
+// startHedge picks a second endpoint for a slow request, if hedging finds one
+func (pr *proxyRequest) startHedge() *upstreamAttempt {
+	candidates, _, _ := pr.eligible(pr.primaryName)
+	endpoint, ok := pr.strategy.Select(candidates)
+	if !ok {
+		return nil
+	}
+	attempt, _ := pr.startAttempt(endpoint, false)
+	if attempt != nil {
+		attempt.span.SetAttribute("ccnexus.hedge", true)
+	}
+	return attempt
+}
+
+// hedgeFailed records an attempt of a hedged request that failed or answered with a
+// retryable status while the other one is pending
+func (pr *proxyRequest) hedgeFailed(attempt *upstreamAttempt, resp *http.Response, err error) {
+	pr.handleUpstream(attempt, resp, err)
+}
+
+// handleUpstream handles the outcome of a sent attempt. It returns true once the client has
+// its answer, and false after recording the failure when the request should move on.
+func (pr *proxyRequest) handleUpstream(attempt *upstreamAttempt, resp *http.Response, err error) bool {
+	p := pr.p
+	endpoint := attempt.endpoint
+	key := attempt.key
+	pr.rec.Endpoint = endpoint.Name
+
+	latency := time.Since(attempt.started)
+	if err != nil {
+		pr.rec.Error = err.Error()
+		attempt.capture.SetError(err.Error())
+		attempt.finishSpan(0, err)
+		p.metrics.recordResponse(endpoint.Name, pr.clientFormat, 0, latency)
+		attempt.log.WithFields(logger.Fields{"latency_ms": latency.Milliseconds()}).Error("[%s] Request failed: %v", endpoint.Name, err)
+		pr.attemptFailed(endpoint, key)
+		return false
+	}
+	attempt.finishSpan(resp.StatusCode, nil)
+	attempt.capture.SetUpstreamResponse(resp.StatusCode, resp.Header)
+	p.metrics.recordResponse(endpoint.Name, pr.clientFormat, resp.StatusCode, latency)
+	attempt.log = attempt.log.WithFields(logger.Fields{"status": resp.StatusCode, "latency_ms": latency.Milliseconds()})
+	attempt.log.Debug("[%s] Upstream responded %d in %s", endpoint.Name, resp.StatusCode, latency.Round(time.Millisecond))
+
+	// 429/529: leave the endpoint (or just the key) alone until its limit resets and try
+	// another one
+	if cooldown := p.applyRateLimit(endpoint, key, resp); isRateLimited(resp.StatusCode) {
+		attempt.drainResponse(resp)
+		p.stats.RecordError(endpoint.Name)
+		p.metrics.recordError(endpoint.Name)
+		p.stats.RecordKeyUsage(endpoint.Name, key, KeyUsage{Errors: 1})
+		p.markRequestInactive(endpoint.Name)
+		p.limiterRefund(endpoint, pr.reservedTokens)
+		// The endpoint answered; throttling does not count against its circuit
+		p.recordBreakerSuccess(endpoint.Name)
+		if p.cooldownRemaining(endpoint.Name) > 0 || !p.hasUsableKey(endpoint) {
+			pr.strategy.OnFailure(endpoint)
+		}
+		attempt.log.DebugLog("[%s] Request throttled %d, cooldown %s", endpoint.Name, resp.StatusCode, cooldown)
+		pr.rec.Error = fmt.Sprintf("upstream returned %d", resp.StatusCode)
+		return false
+	}
+
+	// 401/403 on an endpoint with several keys: drop the key, not the endpoint
+	if (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) && p.markKeyInvalid(endpoint, key) {
+		attempt.drainResponse(resp)
+		p.stats.RecordError(endpoint.Name)
+		p.metrics.recordError(endpoint.Name)
+		p.stats.RecordKeyUsage(endpoint.Name, key, KeyUsage{Errors: 1})
+		p.markRequestInactive(endpoint.Name)
+		p.limiterRefund(endpoint, pr.reservedTokens)
+		p.recordBreakerSuccess(endpoint.Name)
+		pr.rec.Error = fmt.Sprintf("upstream returned %d", resp.StatusCode)
+		return false
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	isStreaming := contentType == "text/event-stream" || (pr.stream && strings.Contains(contentType, "text/event-stream"))
+
+	if resp.StatusCode == http.StatusOK && isStreaming {
+		_, streamSpan := tracing.Start(pr.r.Context(), "transform_stream", tracing.KindInternal)
+		streamSpan.SetAttribute("ccnexus.endpoint", endpoint.Name)
+		streamSpan.SetAttribute("ccnexus.transformer", attempt.transformerName)
+		inputTokens, outputTokens, outputText, err := p.handleStreamingResponse(pr.w, resp, endpoint, attempt.trans, attempt.transformerName, attempt.thinkingEnabled, pr.model, pr.body, attempt.started, attempt.capture)
+		if err != nil {
+			streamSpan.SetError(err.Error())
+		}
+		streamSpan.End()
+		if err != nil {
+			// Nothing has reached the client yet, so the request can move on transparently
+			attempt.log.Warn("[%s] Stream failed early: %v", endpoint.Name, err)
+			attempt.capture.SetError(err.Error())
+			pr.rec.Error = err.Error()
+			pr.attemptFailed(endpoint, key)
+			return false
+		}
+
+		// Fallback: estimate tokens when usage is 0
+		if inputTokens == 0 || outputTokens == 0 {
+			inputTokens, outputTokens = p.estimateTokens(pr.body, outputText, inputTokens, outputTokens, endpoint.Name)
+		}
+		pr.succeeded(attempt, inputTokens, outputTokens)
+		attempt.log.Debug("[%s] Request completed successfully (streaming)", endpoint.Name)
+		return true
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		inputTokens, outputTokens, err := p.handleNonStreamingResponse(pr.w, resp, endpoint, attempt.trans, attempt.capture)
+		if err == nil {
+			pr.succeeded(attempt, inputTokens, outputTokens)
+			attempt.log.Debug("[%s] Request completed successfully", endpoint.Name)
+			return true
+		}
+	}
+
+	if shouldRetry(resp.StatusCode) {
+		var errBody []byte
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			errBody, _ = decompressGzip(resp.Body)
+		} else {
+			errBody, _ = io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+		attempt.capture.AppendUpstreamBody(errBody)
+		errMsg := string(errBody)
+		if len(errMsg) > 200 {
+			errMsg = errMsg[:200] + "..."
+		}
+		attempt.log.Warn("[%s] Request failed %d: %s", endpoint.Name, resp.StatusCode, errMsg)
+		pr.rec.Error = fmt.Sprintf("upstream returned %d: %s", resp.StatusCode, errMsg)
+		attempt.log.DebugLog("[%s] Request failed %d: %s", endpoint.Name, resp.StatusCode, errMsg)
+		pr.attemptFailed(endpoint, key)
+		return false
+	}
+
+	pr.passThrough(attempt, resp)
+	return true
+}
+
+// succeeded records a successful attempt whose response reached the client
+func (pr *proxyRequest) succeeded(attempt *upstreamAttempt, inputTokens, outputTokens int) {
+	p := pr.p
+	endpoint := attempt.endpoint
+	p.stats.RecordTokens(endpoint.Name, inputTokens, outputTokens)
+	p.metrics.recordTokens(endpoint.Name, inputTokens, outputTokens)
+	p.stats.RecordSuccess(endpoint.Name)
+	pr.clientResult = ClientUsage{InputTokens: inputTokens, OutputTokens: outputTokens}
+	pr.rec.InputTokens, pr.rec.OutputTokens, pr.rec.Error = inputTokens, outputTokens, ""
+	pr.span.SetAttribute("gen_ai.usage.input_tokens", inputTokens)
+	pr.span.SetAttribute("gen_ai.usage.output_tokens", outputTokens)
+	p.stats.RecordKeyUsage(endpoint.Name, attempt.key, KeyUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+	p.markKeyValid(endpoint, attempt.key)
+	p.limiterReconcile(endpoint, pr.reservedTokens, inputTokens+outputTokens)
+	p.markRequestInactive(endpoint.Name)
+	p.recordBreakerSuccess(endpoint.Name)
+	p.bindConversation(pr.convKey, endpoint.Name)
+	if p.onEndpointSuccess != nil {
+		p.onEndpointSuccess(endpoint.Name)
+	}
+}
+
+// passThrough sends a response that is not worth retrying, such as a client error, to the
+// client as it is
+func (pr *proxyRequest) passThrough(attempt *upstreamAttempt, resp *http.Response) {
+	p := pr.p
+	endpoint := attempt.endpoint
+
+	var respBody []byte
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		respBody, _ = decompressGzip(resp.Body)
+	} else {
+		respBody, _ = io.ReadAll(resp.Body)
+	}
+	resp.Body.Close()
+	p.markRequestInactive(endpoint.Name)
+	// The endpoint answered; a client error does not count against its circuit
+	p.recordBreakerSuccess(endpoint.Name)
+	if resp.StatusCode < http.StatusBadRequest {
+		p.stats.RecordSuccess(endpoint.Name)
+	} else {
+		p.limiterRefund(endpoint, pr.reservedTokens)
+	}
+	pr.clientResult.Errors = 0
+	pr.rec.Error = ""
+	// Log non-200 responses for debugging
+	if resp.StatusCode != http.StatusOK {
+		errMsg := string(respBody)
+		if len(errMsg) > 500 {
+			errMsg = errMsg[:500] + "..."
+		}
+		attempt.log.Warn("[%s] Response %d: %s", endpoint.Name, resp.StatusCode, errMsg)
+		if resp.StatusCode >= http.StatusBadRequest {
+			pr.rec.Error = fmt.Sprintf("upstream returned %d: %s", resp.StatusCode, errMsg)
+		}
+		attempt.log.DebugLog("[%s] Response %d: %s", endpoint.Name, resp.StatusCode, errMsg)
+	}
+	// Remove Content-Encoding header since we've decompressed
+	for key, values := range resp.Header {
+		if key == "Content-Encoding" || key == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			pr.w.Header().Add(key, value)
+		}
+	}
+	pr.w.WriteHeader(resp.StatusCode)
+	pr.w.Write(respBody)
+	attempt.capture.AppendUpstreamBody(respBody)
+	attempt.capture.SetClientResponse(resp.StatusCode, pr.w.Header())
+	attempt.capture.AppendClientBody(respBody)
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+var (
+	errMissingToken = errors.New("missing access token")
+	errInvalidToken = errors.New("invalid access token")
+	errRevokedToken = errors.New("access token has been revoked")
+	errExpiredToken = errors.New("access token has expired")
+)
+
+// clientToken extracts the access token a client sent in x-api-key or Authorization
+func clientToken(r *http.Request) string {
+	if token := strings.TrimSpace(r.Header.Get("X-Api-Key")); token != "" {
+		return token
+	}
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return auth
+}
+
+// authenticate checks the client's access token. Until the first token is issued the proxy
+// stays open and nil is returned without an error.
+func (p *Proxy) authenticate(r *http.Request) (*config.AccessToken, error) {
+	tokens := p.config.GetAccessTokens()
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	token := clientToken(r)
+	if token == "" {
+		return nil, errMissingToken
+	}
+	hash := config.HashToken(token)
+	for i := range tokens {
+		if subtle.ConstantTimeCompare([]byte(tokens[i].TokenHash), []byte(hash)) != 1 {
+			continue
+		}
+		if tokens[i].Revoked {
+			return nil, errRevokedToken
+		}
+		if tokens[i].Expired(time.Now()) {
+			return nil, errExpiredToken
+		}
+		return &tokens[i], nil
+	}
+	return nil, errInvalidToken
+}
+
+// authorize authenticates a proxy request and answers 401 if it fails. The client's
+// credentials are removed from the request afterwards so they never reach an upstream.
+func (p *Proxy) authorize(w http.ResponseWriter, r *http.Request) (*config.AccessToken, bool) {
+	token, err := p.authenticate(r)
+	if err != nil {
+		logger.Warn("Rejected request from %s: %v", r.RemoteAddr, err)
+		writeClientError(w, detectClientFormat(r.URL.Path), http.StatusUnauthorized, "authentication_error", "invalid_api_key", err.Error())
+		return nil, false
+	}
+	if token != nil {
+		for _, name := range clientAuthHeaders {
+			r.Header.Del(name)
+		}
+	}
+	return token, true
+}
@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Requests flow normally
+	BreakerOpen     = "open"      // Endpoint is skipped until the cooldown expires
+	BreakerHalfOpen = "half_open" // A single probe request decides whether to close again
+)
+
+// BreakerStatus is a snapshot of an endpoint's circuit breaker
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenUntil           *time.Time `json:"openUntil,omitempty"`
+}
+
+// circuitBreaker tracks consecutive failures of a single endpoint
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // a half-open probe request is in flight
+}
+
+// available reports whether a request may be sent, without claiming the probe slot
+func (b *circuitBreaker) available(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= cooldown
+	case BreakerHalfOpen:
+		return !b.probing
+	default:
+		return true
+	}
+}
+
+// acquire claims permission to send a request, turning an expired open breaker into a half-open probe
+func (b *circuitBreaker) acquire(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// success closes the breaker; returns true if it was not closed before
+func (b *circuitBreaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.state == BreakerOpen || b.state == BreakerHalfOpen
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+	return wasOpen
+}
+
+// failure records a failed request; returns true if the breaker (re)opened
+func (b *circuitBreaker) failure(threshold int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		b.probing = false
+		return true
+	}
+	return false
+}
+
+// release gives up a claimed probe slot without judging the endpoint
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// status returns a snapshot of the breaker
+func (b *circuitBreaker) status(cooldown time.Duration) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if status.State == "" {
+		status.State = BreakerClosed
+	}
+	if b.state == BreakerOpen {
+		until := b.openedAt.Add(cooldown)
+		status.OpenUntil = &until
+	}
+	return status
+}
+
+// getBreaker returns the circuit breaker of an endpoint, creating one if needed
+func (p *Proxy) getBreaker(endpointName string) *circuitBreaker {
+	p.breakersMu.Lock()
+	defer p.breakersMu.Unlock()
+
+	b, ok := p.breakers[endpointName]
+	if !ok {
+		b = &circuitBreaker{state: BreakerClosed}
+		p.breakers[endpointName] = b
+	}
+	return b
+}
+
+// breakerAvailable checks if an endpoint's circuit allows traffic
+func (p *Proxy) breakerAvailable(endpointName string) bool {
+	cfg := p.config.GetCircuitBreaker()
+	if cfg.FailureThreshold <= 0 {
+		return true
+	}
+	return p.getBreaker(endpointName).available(time.Duration(cfg.CooldownSeconds) * time.Second)
+}
+
+// breakerAcquire claims permission to send a request to an endpoint
+func (p *Proxy) breakerAcquire(endpointName string) bool {
+	cfg := p.config.GetCircuitBreaker()
+	if cfg.FailureThreshold <= 0 {
+		return true
+	}
+	return p.getBreaker(endpointName).acquire(time.Duration(cfg.CooldownSeconds) * time.Second)
+}
+
+// recordBreakerSuccess closes an endpoint's circuit
+func (p *Proxy) recordBreakerSuccess(endpointName string) {
+	if p.getBreaker(endpointName).success() {
+		logger.Info("[BREAKER] %s: circuit closed", endpointName)
+	}
+}
+
+// recordBreakerFailure counts a failure against an endpoint's circuit
+func (p *Proxy) recordBreakerFailure(endpointName string) {
+	cfg := p.config.GetCircuitBreaker()
+	if cfg.FailureThreshold <= 0 {
+		return
+	}
+	if p.getBreaker(endpointName).failure(cfg.FailureThreshold) {
+		logger.Warn("[BREAKER] %s: circuit opened for %ds", endpointName, cfg.CooldownSeconds)
+	}
+}
+
+// releaseBreaker frees an endpoint's half-open probe slot when a request was abandoned
+func (p *Proxy) releaseBreaker(endpointName string) {
+	p.getBreaker(endpointName).release()
+}
+
+// GetBreakerStatus returns the circuit breaker state of an endpoint
+func (p *Proxy) GetBreakerStatus(endpointName string) BreakerStatus {
+	cfg := p.config.GetCircuitBreaker()
+	return p.getBreaker(endpointName).status(time.Duration(cfg.CooldownSeconds) * time.Second)
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	const threshold = 3
+
+	// Steps run in order against one breaker; cooldownOver makes the open period count as expired
+	type step struct {
+		do           string // "fail", "succeed", "acquire" or "release"
+		cooldownOver bool
+		want         bool   // result of the step
+		wantState    string // state after the step
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "failures below the threshold keep it closed", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+			{do: "acquire", want: true, wantState: BreakerClosed},
+		}},
+		{name: "a success resets the count", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+			{do: "succeed", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+		}},
+		{name: "threshold opens it", steps: []step{
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", wantState: BreakerClosed},
+			{do: "fail", want: true, wantState: BreakerOpen},
+			{do: "acquire", want: false, wantState: BreakerOpen},
+		}},
+		{name: "probe after the cooldown closes it", steps: []step{
+			{do: "fail"}, {do: "fail"}, {do: "fail", want: true, wantState: BreakerOpen},
+			{do: "acquire", cooldownOver: true, want: true, wantState: BreakerHalfOpen},
+			{do: "acquire", cooldownOver: true, want: false, wantState: BreakerHalfOpen},
+			{do: "succeed", want: true, wantState: BreakerClosed},
+			{do: "acquire", want: true, wantState: BreakerClosed},
+		}},
+		{name: "failed probe opens it again", steps: []step{
+			{do: "fail"}, {do: "fail"}, {do: "fail", want: true, wantState: BreakerOpen},
+			{do: "acquire", cooldownOver: true, want: true, wantState: BreakerHalfOpen},
+			{do: "fail", want: true, wantState: BreakerOpen},
+			{do: "acquire", want: false, wantState: BreakerOpen},
+		}},
+		{name: "released probe lets another one through", steps: []step{
+			{do: "fail"}, {do: "fail"}, {do: "fail", want: true, wantState: BreakerOpen},
+			{do: "acquire", cooldownOver: true, want: true, wantState: BreakerHalfOpen},
+			{do: "release", wantState: BreakerHalfOpen},
+			{do: "acquire", want: true, wantState: BreakerHalfOpen},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &circuitBreaker{state: BreakerClosed}
+			for i, s := range tt.steps {
+				cooldown := time.Hour
+				if s.cooldownOver {
+					cooldown = 0
+				}
+				var got bool
+				switch s.do {
+				case "fail":
+					got = b.failure(threshold)
+				case "succeed":
+					got = b.success()
+				case "acquire":
+					if avail := b.available(cooldown); avail != s.want {
+						t.Errorf("step %d: available = %v, want %v", i, avail, s.want)
+					}
+					got = b.acquire(cooldown)
+				case "release":
+					b.release()
+				}
+				if got != s.want {
+					t.Errorf("step %d (%s): got %v, want %v", i, s.do, got, s.want)
+				}
+				if s.wantState != "" && b.state != s.wantState {
+					t.Errorf("step %d (%s): state %s, want %s", i, s.do, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerDisabledByDefault(t *testing.T) {
+	p := New(&config.Config{}, &fakeStatsStorage{}, "test")
+	for i := 0; i < 20; i++ {
+		p.recordBreakerFailure("flaky")
+	}
+	if !p.breakerAvailable("flaky") || !p.breakerAcquire("flaky") {
+		t.Error("circuit opened without a failure threshold configured")
+	}
+	if state := p.GetBreakerStatus("flaky").State; state != BreakerClosed {
+		t.Errorf("state = %s, want %s", state, BreakerClosed)
+	}
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+)
+
+// SetCaptureDir sets the directory captures are written to when the settings name none
+func (p *Proxy) SetCaptureDir(dir string) {
+	p.capture.SetDefaultDir(dir)
+}
+
+// GetCapture returns the recorder of request captures
+func (p *Proxy) GetCapture() *capture.Recorder {
+	return p.capture
+}
+
+// captureSecrets returns the API keys of all endpoints, which are redacted from captures
+func (p *Proxy) captureSecrets() []string {
+	var secrets []string
+	for _, ep := range p.config.GetEndpoints() {
+		secrets = append(secrets, ep.Keys()...)
+	}
+	return secrets
+}
+
+// drainResponse discards an upstream response that is not passed on, keeping its body in the
+// capture of the attempt
+func (a *upstreamAttempt) drainResponse(resp *http.Response) {
+	if a.capture == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return
+	}
+	var body []byte
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		body, _ = decompressGzip(resp.Body)
+	} else {
+		body, _ = io.ReadAll(resp.Body)
+	}
+	resp.Body.Close()
+	a.capture.AppendUpstreamBody(body)
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// slotWaiter is a request queued for a concurrency slot of an endpoint
+type slotWaiter struct {
+	ready chan struct{} // receives the slot handed over by a finishing request
+}
+
+// slotAvailable reports whether an endpoint can take another request right away.
+// Queued requests go first, so a free slot is only available when nobody is waiting.
+// Caller must hold activeRequestsMu.
+func (p *Proxy) slotAvailable(endpoint config.Endpoint) bool {
+	if endpoint.MaxConcurrent <= 0 {
+		return true
+	}
+	if q := p.slotQueues[endpoint.Name]; q != nil && q.Len() > 0 {
+		return false
+	}
+	return p.activeRequests[endpoint.Name] < endpoint.MaxConcurrent
+}
+
+// hasFreeSlot checks if an endpoint is below its concurrency limit
+func (p *Proxy) hasFreeSlot(endpoint config.Endpoint) bool {
+	p.activeRequestsMu.RLock()
+	defer p.activeRequestsMu.RUnlock()
+	return p.slotAvailable(endpoint)
+}
+
+// tryAcquireSlot claims a concurrency slot and marks the request active, without waiting
+func (p *Proxy) tryAcquireSlot(endpoint config.Endpoint) bool {
+	p.activeRequestsMu.Lock()
+	defer p.activeRequestsMu.Unlock()
+
+	if !p.slotAvailable(endpoint) {
+		return false
+	}
+	p.activeRequests[endpoint.Name]++
+	return true
+}
+
+// waitForSlot queues for a concurrency slot in FIFO order. It returns false if the queue
+// is full, the timeout passes or ctx is done; on true the request is marked active.
+func (p *Proxy) waitForSlot(ctx context.Context, endpoint config.Endpoint, queueSize int, timeout time.Duration) bool {
+	p.activeRequestsMu.Lock()
+	if p.slotAvailable(endpoint) {
+		p.activeRequests[endpoint.Name]++
+		p.activeRequestsMu.Unlock()
+		return true
+	}
+
+	q := p.slotQueues[endpoint.Name]
+	if q == nil {
+		q = list.New()
+		p.slotQueues[endpoint.Name] = q
+	}
+	if q.Len() >= queueSize {
+		p.activeRequestsMu.Unlock()
+		return false
+	}
+	waiter := &slotWaiter{ready: make(chan struct{}, 1)}
+	elem := q.PushBack(waiter)
+	p.activeRequestsMu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	p.activeRequestsMu.Lock()
+	defer p.activeRequestsMu.Unlock()
+	select {
+	case <-waiter.ready:
+		// The slot was handed over while we were giving up
+		return true
+	default:
+		q.Remove(elem)
+		return false
+	}
+}
+
+// handOverSlot passes a finishing request's slot to the first queued request.
+// Caller must hold activeRequestsMu.
+func (p *Proxy) handOverSlot(endpointName string) bool {
+	q := p.slotQueues[endpointName]
+	if q == nil || q.Len() == 0 {
+		return false
+	}
+	waiter := q.Remove(q.Front()).(*slotWaiter)
+	waiter.ready <- struct{}{}
+	return true
+}
+
+// GetInFlight returns the number of active requests on an endpoint
+func (p *Proxy) GetInFlight(endpointName string) int {
+	return p.inFlight(endpointName)
+}
+
+// QueueLength returns the number of requests waiting for a slot of an endpoint
+func (p *Proxy) QueueLength(endpointName string) int {
+	p.activeRequestsMu.RLock()
+	defer p.activeRequestsMu.RUnlock()
+	if q := p.slotQueues[endpointName]; q != nil {
+		return q.Len()
+	}
+	return 0
+}
@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// StatusOverloaded is the non-standard status Anthropic uses when the API is overloaded
+const StatusOverloaded = 529
+
+const (
+	defaultRateLimitCooldown = 30 * time.Second // 429 without any usable reset header
+	defaultOverloadCooldown  = 10 * time.Second // 529 without any usable reset header
+	maxCooldown              = 10 * time.Minute // upper bound for header-derived cooldowns
+	maxCooldownWait          = 60 * time.Second // longest a request waits for endpoints to cool down
+	backoffBase              = 500 * time.Millisecond
+	backoffMax               = 8 * time.Second
+)
+
+// rateLimitHeaderPrefixes lists the providers' rate limit header families
+var rateLimitHeaderPrefixes = []string{"anthropic-ratelimit-", "x-ratelimit-"}
+
+// isRateLimited checks if a status code means the endpoint is throttling us
+func isRateLimited(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == StatusOverloaded
+}
+
+// rateLimitCooldown works out how long an endpoint should be left alone after a response.
+// Rate limited responses always cool down (falling back to a default), other responses
+// only when a rate limit header reports an exhausted bucket.
+func rateLimitCooldown(statusCode int, header http.Header, now time.Time) time.Duration {
+	limited := isRateLimited(statusCode)
+
+	wait := time.Duration(0)
+	if limited {
+		wait = parseRetryAfter(header.Get("Retry-After"), now)
+	}
+	if wait <= 0 {
+		wait = rateLimitReset(header, now, true)
+	}
+	if wait <= 0 && limited {
+		wait = rateLimitReset(header, now, false)
+	}
+	if wait <= 0 && limited {
+		wait = defaultRateLimitCooldown
+		if statusCode == StatusOverloaded {
+			wait = defaultOverloadCooldown
+		}
+	}
+	if wait > maxCooldown {
+		wait = maxCooldown
+	}
+	return wait
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// rateLimitReset returns the longest reset time among the rate limit buckets.
+// With exhaustedOnly set, only buckets whose remaining count is 0 are considered.
+func rateLimitReset(header http.Header, now time.Time, exhaustedOnly bool) time.Duration {
+	var wait time.Duration
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if len(values) == 0 {
+			continue
+		}
+		for _, prefix := range rateLimitHeaderPrefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			bucket, ok := resetBucket(strings.TrimPrefix(key, prefix))
+			if !ok {
+				continue
+			}
+			if exhaustedOnly && !bucketExhausted(header, prefix, bucket) {
+				continue
+			}
+			if d := parseResetValue(values[0], now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// resetBucket extracts the bucket name from a reset header suffix:
+// "requests-reset" (Anthropic) or "reset-requests" (OpenAI style)
+func resetBucket(suffix string) (string, bool) {
+	if strings.HasSuffix(suffix, "-reset") {
+		return strings.TrimSuffix(suffix, "-reset"), true
+	}
+	if strings.HasPrefix(suffix, "reset-") {
+		return strings.TrimPrefix(suffix, "reset-"), true
+	}
+	return "", false
+}
+
+// bucketExhausted checks the matching remaining header of a bucket
+func bucketExhausted(header http.Header, prefix, bucket string) bool {
+	remaining := header.Get(prefix + bucket + "-remaining")
+	if remaining == "" {
+		remaining = header.Get(prefix + "remaining-" + bucket)
+	}
+	return strings.TrimSpace(remaining) == "0"
+}
+
+// parseResetValue parses a reset header given as an RFC 3339 timestamp, a Go-style
+// duration (e.g. "6m0s", "20ms") or a number of seconds
+func parseResetValue(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now)
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	return 0
+}
+
+// backoffDelay returns the exponential backoff for the given attempt with jitter
+// (half fixed, half random) so that concurrent requests do not retry in lockstep
+func backoffDelay(attempt int) time.Duration {
+	d := backoffBase << uint(attempt)
+	if d > backoffMax || d <= 0 {
+		d = backoffMax
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// setCooldown keeps an endpoint out of rotation for the given duration.
+// An existing longer cooldown is not shortened.
+func (p *Proxy) setCooldown(endpointName string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	until := time.Now().Add(d)
+
+	p.cooldownsMu.Lock()
+	defer p.cooldownsMu.Unlock()
+	if current, ok := p.cooldowns[endpointName]; ok && current.After(until) {
+		return
+	}
+	p.cooldowns[endpointName] = until
+}
+
+// cooldownRemaining returns how long an endpoint is still cooling down (0 if it is not)
+func (p *Proxy) cooldownRemaining(endpointName string) time.Duration {
+	p.cooldownsMu.Lock()
+	defer p.cooldownsMu.Unlock()
+
+	until, ok := p.cooldowns[endpointName]
+	if !ok {
+		return 0
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(p.cooldowns, endpointName)
+		return 0
+	}
+	return remaining
+}
+
+// applyRateLimit puts an endpoint into cooldown based on a response; returns the cooldown.
+// On endpoints with several API keys a 429 only cools down the key that hit it, since the
+// limits belong to the key; an overloaded upstream (529) still cools the whole endpoint.
+func (p *Proxy) applyRateLimit(endpoint config.Endpoint, key string, resp *http.Response) time.Duration {
+	wait := rateLimitCooldown(resp.StatusCode, resp.Header, time.Now())
+	if wait <= 0 {
+		return 0
+	}
+	if resp.StatusCode != StatusOverloaded && len(endpoint.Keys()) > 1 {
+		p.setKeyCooldown(endpoint, key, wait)
+		logger.Warn("[%s] Rate limit reached (HTTP %d) for API key %s, cooling it down for %s", endpoint.Name, resp.StatusCode, keyLabel(endpoint, key), wait.Round(time.Millisecond))
+		return wait
+	}
+	p.setCooldown(endpoint.Name, wait)
+	logger.Warn("[%s] Rate limit reached (HTTP %d), cooling down for %s", endpoint.Name, resp.StatusCode, wait.Round(time.Millisecond))
+	return wait
+}
+
+// shortestWait returns the shortest time until one of the endpoints not in skip is out of
+// its cooldown, has an API key out of cooldown and has RPM/TPM budget for a request of
+// tokens (0 if none is held back)
+func (p *Proxy) shortestWait(endpoints []config.Endpoint, skip map[string]bool, tokens int) time.Duration {
+	var shortest time.Duration
+	for _, ep := range endpoints {
+		if skip[ep.Name] {
+			continue
+		}
+		remaining := p.cooldownRemaining(ep.Name)
+		if wait := p.limiterWait(ep, tokens); wait > remaining {
+			remaining = wait
+		}
+		if wait := p.keyWait(ep); wait > remaining {
+			remaining = wait
+		}
+		if remaining > 0 && (shortest == 0 || remaining < shortest) {
+			shortest = remaining
+		}
+	}
+	return shortest
+}
+
+// GetCooldownUntil returns when an endpoint's rate limit cooldown ends, or nil if it is not cooling down
+func (p *Proxy) GetCooldownUntil(endpointName string) *time.Time {
+	remaining := p.cooldownRemaining(endpointName)
+	if remaining <= 0 {
+		return nil
+	}
+	until := time.Now().Add(remaining)
+	return &until
+}
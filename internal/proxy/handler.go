@@ -9,19 +9,66 @@ import (
 	"github.com/lich0821/ccNexus/internal/tokencount"
 )
 
-// handleHealth handles health check requests
+// handleHealth reports the state of the enabled endpoints. It is safe to expose:
+// no URLs or API keys are included.
 func (p *Proxy) handleHealth(w http.ResponseWriter, r *http.Request) {
+	endpoints := p.GetEndpointHealth()
+	available := 0
+	for _, ep := range endpoints {
+		if ep.Status == EndpointAvailable {
+			available++
+		}
+	}
+
+	status := "healthy"
+	if available == 0 {
+		status = "degraded"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":              status,
+		"enabled_endpoints":   len(endpoints),
+		"available_endpoints": available,
+		"endpoints":           endpoints,
+	})
+}
 
-	endpoints := p.getEnabledEndpoints()
-	response := map[string]interface{}{
-		"status":            "healthy",
-		"enabled_endpoints": len(endpoints),
-		"endpoints":         endpoints,
+// handleReady answers 200 once the database is reachable and at least one endpoint can take
+// requests, 503 otherwise, for use as a readiness probe
+func (p *Proxy) handleReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"database": "ok", "endpoints": "ok"}
+	ready := true
+
+	if err := p.stats.storage.Ping(); err != nil {
+		logger.Warn("Readiness check: database unreachable: %v", err)
+		checks["database"] = "unreachable"
+		ready = false
 	}
 
-	json.NewEncoder(w).Encode(response)
+	available := false
+	for _, ep := range p.getEnabledEndpoints() {
+		if p.endpointStatus(ep) == EndpointAvailable {
+			available = true
+			break
+		}
+	}
+	if !available {
+		checks["endpoints"] = "no available endpoint"
+		ready = false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready":  ready,
+		"checks": checks,
+	})
 }
 
 // handleStats handles statistics requests
@@ -42,6 +89,9 @@ func (p *Proxy) handleCountTokens(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if _, ok := p.authorize(w, r); !ok {
+		return
+	}
 
 	var req struct {
 		Model    string                   `json:"model"`
@@ -119,6 +169,7 @@ func (p *Proxy) UpdateConfig(cfg *config.Config) error {
 	}
 
 	p.config = cfg
+	p.transports.prune(cfg)
 
 	// Try to find the previous current endpoint in new config
 	newEndpoints := p.getEnabledEndpoints()
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// Endpoint health states reported by /health
+const (
+	EndpointAvailable     = "available"
+	EndpointCircuitOpen   = "circuit_open"
+	EndpointCoolingDown   = "cooling_down"
+	EndpointKeysExhausted = "keys_exhausted"
+)
+
+// EndpointHealth is the state of one enabled endpoint, without its URL or credentials
+type EndpointHealth struct {
+	Name          string        `json:"name"`
+	Transformer   string        `json:"transformer"`
+	Status        string        `json:"status"`
+	Breaker       BreakerStatus `json:"breaker"`
+	CooldownUntil *time.Time    `json:"cooldownUntil,omitempty"`
+	LastSuccess   *time.Time    `json:"lastSuccess,omitempty"`
+	LastError     *time.Time    `json:"lastError,omitempty"`
+	InFlight      int           `json:"inFlight"`
+	Queued        int           `json:"queued"`
+}
+
+// endpointStatus tells whether an endpoint can take a request now, and if not, why
+func (p *Proxy) endpointStatus(endpoint config.Endpoint) string {
+	switch {
+	case !p.breakerAvailable(endpoint.Name):
+		return EndpointCircuitOpen
+	case p.cooldownRemaining(endpoint.Name) > 0:
+		return EndpointCoolingDown
+	case !p.hasUsableKey(endpoint):
+		return EndpointKeysExhausted
+	}
+	return EndpointAvailable
+}
+
+// GetEndpointHealth returns the health of every enabled endpoint
+func (p *Proxy) GetEndpointHealth() []EndpointHealth {
+	endpoints := p.getEnabledEndpoints()
+	health := make([]EndpointHealth, 0, len(endpoints))
+	for _, ep := range endpoints {
+		activity := p.stats.GetActivity(ep.Name)
+		health = append(health, EndpointHealth{
+			Name:          ep.Name,
+			Transformer:   ep.Transformer,
+			Status:        p.endpointStatus(ep),
+			Breaker:       p.GetBreakerStatus(ep.Name),
+			CooldownUntil: p.GetCooldownUntil(ep.Name),
+			LastSuccess:   activity.LastSuccess,
+			LastError:     activity.LastError,
+			InFlight:      p.GetInFlight(ep.Name),
+			Queued:        p.QueueLength(ep.Name),
+		})
+	}
+	return health
+}
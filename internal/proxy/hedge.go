@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/tracing"
+	"github.com/lich0821/ccNexus/internal/transformer"
+)
+
+// upstreamAttempt is a client request prepared for one endpoint
+type upstreamAttempt struct {
+	endpoint        config.Endpoint
+	key             string // API key the request is sent with
+	trans           transformer.Transformer
+	transformerName string
+	thinkingEnabled bool
+	proxyReq        *http.Request
+	body            []byte              // transformed request body, kept for captures
+	reservedTokens  int                 // tokens reserved against the endpoint's TPM limit
+	cancel          context.CancelFunc  // set once the attempt is sent with its own context
+	started         time.Time           // when the attempt was prepared, for latency metrics
+	span            *tracing.Span       // trace span of the attempt, nil when not traced
+	log             *logger.FieldLogger // logs with the request ID and attempt fields
+	capture         *capture.Exchange   // full capture of the attempt, nil when not captured
+}
+
+// attemptResult is the outcome of sending an attempt
+type attemptResult struct {
+	attempt *upstreamAttempt
+	resp    *http.Response
+	err     error
+}
+
+// prepareAttempt transforms the client request for an endpoint and builds the upstream request
+// authenticated with the given API key
+func prepareAttempt(r *http.Request, clientFormat ClientFormat, endpoint config.Endpoint, key string, model string, bodyBytes []byte) (*upstreamAttempt, error) {
+	targetModel := endpoint.ResolveModel(model)
+	trans, err := prepareTransformerForClient(clientFormat, endpoint, targetModel)
+	if err != nil {
+		return nil, err
+	}
+
+	transformerName := trans.Name()
+
+	_, span := tracing.Start(r.Context(), "transform_request", tracing.KindInternal)
+	span.SetAttribute("ccnexus.transformer", transformerName)
+	transformedBody, err := trans.TransformRequest(bodyBytes)
+	if err != nil {
+		span.SetError(err.Error())
+		span.End()
+		return nil, fmt.Errorf("failed to transform request: %w", err)
+	}
+	span.End()
+
+	logger.DebugLog("[%s] Transformer: %s", endpoint.Name, transformerName)
+	logger.DebugLog("[%s] Transformed Request: %s", endpoint.Name, string(transformedBody))
+
+	cleanedBody, err := cleanIncompleteToolCalls(transformedBody)
+	if err != nil {
+		logger.Warn("[%s] Failed to clean tool calls: %v", endpoint.Name, err)
+		cleanedBody = transformedBody
+	}
+	transformedBody = cleanedBody
+
+	var thinkingEnabled bool
+	if strings.Contains(transformerName, "openai") {
+		var openaiReq map[string]interface{}
+		if err := json.Unmarshal(transformedBody, &openaiReq); err == nil {
+			if enable, ok := openaiReq["enable_thinking"].(bool); ok {
+				thinkingEnabled = enable
+			}
+		}
+	}
+
+	keyed := endpoint
+	keyed.APIKey = key
+	proxyReq, err := buildProxyRequest(r, keyed, targetModel, transformedBody, transformerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return &upstreamAttempt{
+		endpoint:        endpoint,
+		key:             key,
+		trans:           trans,
+		transformerName: transformerName,
+		thinkingEnabled: thinkingEnabled,
+		proxyReq:        proxyReq,
+		body:            transformedBody,
+		started:         time.Now(),
+	}, nil
+}
+
+// sendHedged sends the primary attempt and, if it has not answered within delay, a second
+// attempt obtained from startHedge (which may return nil if there is no other endpoint).
+// The first successful or non-retryable response to start its body wins and the other attempt
+// is cancelled, so an upstream that sends its headers and then stalls does not win.
+// Attempts that fail or answer with a retryable status while another one is still pending
+// are reported through onFailure; the last outcome is returned.
+func (p *Proxy) sendHedged(primary *upstreamAttempt, delay time.Duration, startHedge func() *upstreamAttempt, onFailure func(*upstreamAttempt, *http.Response, error)) (*upstreamAttempt, *http.Response, error) {
+	results := make(chan attemptResult, 2)
+	send := func(a *upstreamAttempt) {
+		ctx, cancel := context.WithCancel(p.getEndpointContext(a.endpoint.Name))
+		a.cancel = cancel
+		go func() {
+			resp, err := p.sendRequest(ctx, a.endpoint, a.proxyReq)
+			if err == nil && !shouldRetry(resp.StatusCode) {
+				if err = awaitFirstByte(resp, p.firstByteTimeout(resp)); err != nil {
+					resp = nil
+				}
+			}
+			results <- attemptResult{attempt: a, resp: resp, err: err}
+		}()
+	}
+
+	send(primary)
+	pending := 1
+	var hedge *upstreamAttempt
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if hedge = startHedge(); hedge != nil {
+				primary.log.Info("[HEDGE] %s has not answered within %s, also sending to %s", primary.endpoint.Name, delay, hedge.endpoint.Name)
+				p.stats.RecordHedged()
+				send(hedge)
+				pending++
+			}
+
+		case res := <-results:
+			pending--
+			if res.err == nil && !shouldRetry(res.resp.StatusCode) {
+				if pending > 0 {
+					loser := primary
+					if res.attempt == primary {
+						loser = hedge
+					}
+					p.abandonAttempt(loser, results)
+				}
+				if hedge != nil {
+					res.attempt.log.Debug("[HEDGE] %s answered first", res.attempt.endpoint.Name)
+				}
+				return res.attempt, res.resp, nil
+			}
+
+			if pending == 0 {
+				// Nothing else in flight; the hedge timer no longer matters either
+				return res.attempt, res.resp, res.err
+			}
+			onFailure(res.attempt, res.resp, res.err)
+		}
+	}
+}
+
+// firstByteTimeout returns how long a hedged response may take to start its body: the
+// first-event timeout for streams, no limit otherwise
+func (p *Proxy) firstByteTimeout(resp *http.Response) time.Duration {
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return 0
+	}
+	return time.Duration(p.config.GetStreaming().FirstEventTimeout) * time.Second
+}
+
+// awaitFirstByte waits until the body of a response has its first byte, or is empty, and
+// keeps that byte readable. On error, including no byte within timeout (if > 0), the body
+// is closed.
+func awaitFirstByte(resp *http.Response, timeout time.Duration) error {
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() { resp.Body.Close() })
+	}
+
+	body := bufio.NewReader(resp.Body)
+	_, err := body.Peek(1)
+	// Once the timer fired the body is closed, even if the byte made it in just before
+	if timer != nil && !timer.Stop() {
+		resp.Body.Close()
+		return fmt.Errorf("no stream event within %s", timeout)
+	}
+	if err != nil && err != io.EOF {
+		resp.Body.Close()
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}
+	return nil
+}
+
+// abandonAttempt cancels the losing attempt of a hedged request and discards its outcome
+func (p *Proxy) abandonAttempt(loser *upstreamAttempt, results <-chan attemptResult) {
+	loser.cancel()
+	loser.span.SetAttribute("ccnexus.hedge_cancelled", true)
+	loser.capture.SetError("cancelled: another endpoint answered first")
+	loser.span.End()
+	p.markRequestInactive(loser.endpoint.Name)
+	p.releaseBreaker(loser.endpoint.Name)
+	p.limiterRefund(loser.endpoint, loser.reservedTokens)
+	loser.log.Debug("[HEDGE] Cancelled request to %s", loser.endpoint.Name)
+
+	go func() {
+		if res := <-results; res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}()
+}
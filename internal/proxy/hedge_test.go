@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// upstream answers with status after delay and sends its body after stall; status 0 drops
+// the connection instead
+type upstream struct {
+	status int
+	delay  time.Duration
+	stall  time.Duration
+}
+
+func (u upstream) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(u.delay):
+		case <-r.Context().Done():
+			return
+		}
+		if u.status == 0 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(u.status)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(u.stall):
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, "event: ping\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testAttempt(t *testing.T, name, url string) *upstreamAttempt {
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &upstreamAttempt{
+		endpoint: config.Endpoint{Name: name, APIUrl: url, Enabled: true},
+		proxyReq: req,
+		started:  time.Now(),
+		log:      logger.WithFields(logger.Fields{"endpoint": name}),
+	}
+}
+
+func TestSendHedgedWinner(t *testing.T) {
+	const slow = 300 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		primary      upstream
+		hedge        *upstream // nil when there is no other endpoint
+		wantWinner   string
+		wantStatus   int // 0 for a transport error
+		wantFailures []string
+	}{
+		{name: "primary answers before the hedge delay", primary: upstream{status: 200}, hedge: &upstream{status: 200},
+			wantWinner: "primary", wantStatus: 200},
+		{name: "faster hedge wins", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 200},
+			wantWinner: "hedge", wantStatus: 200},
+		{name: "primary that stalls after its headers loses", primary: upstream{status: 200, stall: slow}, hedge: &upstream{status: 200},
+			wantWinner: "hedge", wantStatus: 200},
+		{name: "stalled primary still wins when nothing else is pending", primary: upstream{status: 200, stall: slow},
+			wantWinner: "primary", wantStatus: 200},
+		{name: "client error from the hedge wins", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 400},
+			wantWinner: "hedge", wantStatus: 400},
+		{name: "overloaded hedge does not win", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 529},
+			wantWinner: "primary", wantStatus: 200, wantFailures: []string{"hedge"}},
+		{name: "rate limited hedge does not win", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 429},
+			wantWinner: "primary", wantStatus: 200, wantFailures: []string{"hedge"}},
+		{name: "server error from the hedge does not win", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 502},
+			wantWinner: "primary", wantStatus: 200, wantFailures: []string{"hedge"}},
+		{name: "broken hedge does not win", primary: upstream{status: 200, delay: slow}, hedge: &upstream{status: 0},
+			wantWinner: "primary", wantStatus: 200, wantFailures: []string{"hedge"}},
+		{name: "last retryable answer is returned", primary: upstream{status: 503, delay: slow}, hedge: &upstream{status: 500},
+			wantWinner: "primary", wantStatus: 503, wantFailures: []string{"hedge"}},
+		{name: "retryable answer without a hedge is returned", primary: upstream{status: 429},
+			wantWinner: "primary", wantStatus: 429},
+		{name: "no other endpoint", primary: upstream{status: 500, delay: slow},
+			wantWinner: "primary", wantStatus: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(&config.Config{}, &fakeStatsStorage{}, "test")
+			primary := testAttempt(t, "primary", tt.primary.start(t).URL)
+			startHedge := func() *upstreamAttempt {
+				if tt.hedge == nil {
+					return nil
+				}
+				return testAttempt(t, "hedge", tt.hedge.start(t).URL)
+			}
+			var failures []string
+			onFailure := func(a *upstreamAttempt, resp *http.Response, err error) {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				failures = append(failures, a.endpoint.Name)
+			}
+
+			winner, resp, err := p.sendHedged(primary, 50*time.Millisecond, startHedge, onFailure)
+			defer winner.cancel()
+			status := 0
+			if err == nil {
+				status = resp.StatusCode
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != "event: ping\n\n" {
+					t.Errorf("body = %q, want the whole event", body)
+				}
+			}
+
+			if winner.endpoint.Name != tt.wantWinner || status != tt.wantStatus {
+				t.Errorf("got %s with %d (err %v), want %s with %d", winner.endpoint.Name, status, err, tt.wantWinner, tt.wantStatus)
+			}
+			if len(failures) != len(tt.wantFailures) || (len(failures) > 0 && failures[0] != tt.wantFailures[0]) {
+				t.Errorf("failures = %v, want %v", failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestAwaitFirstByte(t *testing.T) {
+	tests := []struct {
+		name    string
+		up      upstream
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "body right away", up: upstream{status: 200}},
+		{name: "body after a pause without timeout", up: upstream{status: 200, stall: 50 * time.Millisecond}},
+		{name: "body within the timeout", up: upstream{status: 200, stall: 20 * time.Millisecond}, timeout: time.Second},
+		{name: "stall past the timeout", up: upstream{status: 200, stall: time.Second}, timeout: 50 * time.Millisecond, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(tt.up.start(t).URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			err = awaitFirstByte(resp, tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if body, _ := io.ReadAll(resp.Body); string(body) != "event: ping\n\n" {
+					t.Errorf("body = %q, want the whole event", body)
+				}
+			}
+		})
+	}
+}
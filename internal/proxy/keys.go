@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// badKeyRetry is how long a key rejected with 401/403 is left out before it is tried again
+const badKeyRetry = 10 * time.Minute
+
+// keyState tracks one API key of an endpoint
+type keyState struct {
+	cooldownUntil   time.Time // rate limited or rejected until then
+	lastRateLimited time.Time // last 429 answered for this key
+	invalid         bool      // rejected with 401/403 and not accepted since
+}
+
+// keyPool rotates the API keys of one endpoint
+type keyPool struct {
+	mu     sync.Mutex
+	next   int // round-robin position
+	states map[string]*keyState
+}
+
+// KeyStatus is a snapshot of one API key of an endpoint
+type KeyStatus struct {
+	Invalid         bool       `json:"invalid"`
+	CooldownUntil   *time.Time `json:"cooldownUntil,omitempty"`
+	LastRateLimited *time.Time `json:"lastRateLimited,omitempty"`
+	Usage           KeyUsage   `json:"usage"`
+}
+
+// state returns the state of a key, creating it if needed. Caller must hold mu.
+func (kp *keyPool) state(key string) *keyState {
+	st, ok := kp.states[key]
+	if !ok {
+		st = &keyState{}
+		kp.states[key] = st
+	}
+	return st
+}
+
+// getKeyPool returns the key pool of an endpoint, creating one if needed
+func (p *Proxy) getKeyPool(endpointName string) *keyPool {
+	p.keyPoolsMu.Lock()
+	defer p.keyPoolsMu.Unlock()
+
+	kp, ok := p.keyPools[endpointName]
+	if !ok {
+		kp = &keyPool{states: make(map[string]*keyState)}
+		p.keyPools[endpointName] = kp
+	}
+	return kp
+}
+
+// selectKey picks the API key for the next request to an endpoint following its key strategy.
+// Returns false if every key is cooling down or was rejected.
+func (p *Proxy) selectKey(endpoint config.Endpoint) (string, bool) {
+	keys := endpoint.Keys()
+	if len(keys) <= 1 {
+		// A single key shares the fate of its endpoint
+		return endpoint.APIKey, true
+	}
+
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := time.Now()
+	best := -1
+	for i := 0; i < len(keys); i++ {
+		idx := (kp.next + i) % len(keys)
+		st := kp.state(keys[idx])
+		if now.Before(st.cooldownUntil) {
+			continue
+		}
+		if best < 0 {
+			best = idx
+			if endpoint.KeyStrategy != config.KeyStrategyLeastRecent429 {
+				break
+			}
+			continue
+		}
+		if st.lastRateLimited.Before(kp.state(keys[best]).lastRateLimited) {
+			best = idx
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	kp.next = best + 1
+	return keys[best], true
+}
+
+// hasUsableKey checks if an endpoint has a key that is neither cooling down nor rejected
+func (p *Proxy) hasUsableKey(endpoint config.Endpoint) bool {
+	keys := endpoint.Keys()
+	if len(keys) <= 1 {
+		return true
+	}
+
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if !now.Before(kp.state(key).cooldownUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWait returns how long until a rate limited key of an endpoint can be used again
+// (0 if one can be used now or only rejected keys are left)
+func (p *Proxy) keyWait(endpoint config.Endpoint) time.Duration {
+	keys := endpoint.Keys()
+	if len(keys) <= 1 {
+		return 0
+	}
+
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	var shortest time.Duration
+	for _, key := range keys {
+		st := kp.state(key)
+		if st.invalid {
+			continue
+		}
+		remaining := time.Until(st.cooldownUntil)
+		if remaining <= 0 {
+			return 0
+		}
+		if shortest == 0 || remaining < shortest {
+			shortest = remaining
+		}
+	}
+	return shortest
+}
+
+// setKeyCooldown keeps a rate limited key out of rotation for the given duration
+func (p *Proxy) setKeyCooldown(endpoint config.Endpoint, key string, d time.Duration) {
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := time.Now()
+	st := kp.state(key)
+	st.lastRateLimited = now
+	if until := now.Add(d); until.After(st.cooldownUntil) {
+		st.cooldownUntil = until
+	}
+}
+
+// markKeyInvalid takes a key rejected with 401/403 out of rotation. It only applies to
+// endpoints with several keys and returns false otherwise, leaving the failure to the endpoint.
+func (p *Proxy) markKeyInvalid(endpoint config.Endpoint, key string) bool {
+	if len(endpoint.Keys()) <= 1 {
+		return false
+	}
+
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	st := kp.state(key)
+	st.invalid = true
+	st.cooldownUntil = time.Now().Add(badKeyRetry)
+	logger.Warn("[%s] API key %s rejected, retrying it in %s", endpoint.Name, keyLabel(endpoint, key), badKeyRetry)
+	return true
+}
+
+// markKeyValid clears the rejected flag of a key once it is accepted again
+func (p *Proxy) markKeyValid(endpoint config.Endpoint, key string) {
+	if len(endpoint.Keys()) <= 1 {
+		return
+	}
+
+	kp := p.getKeyPool(endpoint.Name)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if st, ok := kp.states[key]; ok && st.invalid {
+		st.invalid = false
+		logger.Info("[%s] API key %s accepted again", endpoint.Name, keyLabel(endpoint, key))
+	}
+}
+
+// GetKeyStatus returns the rotation state and usage of one API key of an endpoint
+func (p *Proxy) GetKeyStatus(endpointName, key string) KeyStatus {
+	status := KeyStatus{Usage: p.stats.GetKeyUsage(endpointName, key)}
+
+	kp := p.getKeyPool(endpointName)
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	st, ok := kp.states[key]
+	if !ok {
+		return status
+	}
+	status.Invalid = st.invalid
+	if time.Now().Before(st.cooldownUntil) {
+		until := st.cooldownUntil
+		status.CooldownUntil = &until
+	}
+	if !st.lastRateLimited.IsZero() {
+		last := st.lastRateLimited
+		status.LastRateLimited = &last
+	}
+	return status
+}
+
+// keyLabel names a key in logs without revealing it: its label if set, otherwise the last 4 characters
+func keyLabel(endpoint config.Endpoint, key string) string {
+	for _, k := range endpoint.APIKeys {
+		if k.Key == key && k.Label != "" {
+			return k.Label
+		}
+	}
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
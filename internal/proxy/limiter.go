@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// tokenBucket holds up to one minute worth of a per-minute limit and refills continuously.
+// The level may go negative when actual usage turns out higher than reserved.
+type tokenBucket struct {
+	level   float64
+	updated time.Time
+}
+
+// refill tops up the bucket for the time passed since the last update
+func (b *tokenBucket) refill(perMinute float64, now time.Time) {
+	if b.updated.IsZero() {
+		b.level = perMinute
+	} else {
+		b.level += now.Sub(b.updated).Minutes() * perMinute
+		if b.level > perMinute {
+			b.level = perMinute
+		}
+	}
+	b.updated = now
+}
+
+// allows checks if n units can be taken. A full bucket always admits one request,
+// so requests larger than the whole limit are not blocked forever.
+func (b *tokenBucket) allows(n, perMinute float64) bool {
+	return b.level >= n || b.level >= perMinute
+}
+
+// wait returns how long until n units can be taken
+func (b *tokenBucket) wait(n, perMinute float64) time.Duration {
+	if b.allows(n, perMinute) {
+		return 0
+	}
+	if n > perMinute {
+		n = perMinute
+	}
+	return time.Duration((n - b.level) / perMinute * float64(time.Minute))
+}
+
+// endpointLimiter enforces the RPM/TPM limits of one endpoint
+type endpointLimiter struct {
+	mu       sync.Mutex
+	requests tokenBucket
+	tokens   tokenBucket
+}
+
+// getLimiter returns the rate limiter of an endpoint, creating one if needed
+func (p *Proxy) getLimiter(endpointName string) *endpointLimiter {
+	p.limitersMu.Lock()
+	defer p.limitersMu.Unlock()
+
+	l, ok := p.limiters[endpointName]
+	if !ok {
+		l = &endpointLimiter{}
+		p.limiters[endpointName] = l
+	}
+	return l
+}
+
+// limiterWait returns how long until an endpoint's RPM/TPM limits admit a request
+// estimated at tokens (0 if it can be sent now)
+func (p *Proxy) limiterWait(endpoint config.Endpoint, tokens int) time.Duration {
+	if endpoint.RPM <= 0 && endpoint.TPM <= 0 {
+		return 0
+	}
+
+	l := p.getLimiter(endpoint.Name)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if endpoint.RPM > 0 {
+		l.requests.refill(float64(endpoint.RPM), now)
+		wait = l.requests.wait(1, float64(endpoint.RPM))
+	}
+	if endpoint.TPM > 0 {
+		l.tokens.refill(float64(endpoint.TPM), now)
+		if w := l.tokens.wait(float64(tokens), float64(endpoint.TPM)); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// limiterReserve takes one request and the estimated tokens from an endpoint's buckets;
+// returns false without taking anything if either limit is exhausted
+func (p *Proxy) limiterReserve(endpoint config.Endpoint, tokens int) bool {
+	if endpoint.RPM <= 0 && endpoint.TPM <= 0 {
+		return true
+	}
+
+	l := p.getLimiter(endpoint.Name)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if endpoint.RPM > 0 {
+		l.requests.refill(float64(endpoint.RPM), now)
+		if !l.requests.allows(1, float64(endpoint.RPM)) {
+			return false
+		}
+	}
+	if endpoint.TPM > 0 {
+		l.tokens.refill(float64(endpoint.TPM), now)
+		if !l.tokens.allows(float64(tokens), float64(endpoint.TPM)) {
+			return false
+		}
+	}
+
+	if endpoint.RPM > 0 {
+		l.requests.level--
+	}
+	if endpoint.TPM > 0 {
+		l.tokens.level -= float64(tokens)
+	}
+	return true
+}
+
+// limiterReconcile corrects an endpoint's TPM bucket once the actual usage (input plus
+// output tokens) of a request is known; unknown usage keeps the reservation
+func (p *Proxy) limiterReconcile(endpoint config.Endpoint, reserved, actual int) {
+	if endpoint.TPM <= 0 || actual <= 0 || reserved == actual {
+		return
+	}
+
+	l := p.getLimiter(endpoint.Name)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens.refill(float64(endpoint.TPM), time.Now())
+	l.tokens.level -= float64(actual - reserved)
+	if l.tokens.level > float64(endpoint.TPM) {
+		l.tokens.level = float64(endpoint.TPM)
+	}
+	logger.Debug("[%s] TPM reconciled: reserved %d, used %d", endpoint.Name, reserved, actual)
+}
+
+// limiterRefund gives the tokens reserved for an attempt back to an endpoint's TPM bucket
+// when the attempt used none, e.g. because it failed, was throttled or was abandoned
+func (p *Proxy) limiterRefund(endpoint config.Endpoint, reserved int) {
+	if endpoint.TPM <= 0 || reserved <= 0 {
+		return
+	}
+
+	l := p.getLimiter(endpoint.Name)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens.refill(float64(endpoint.TPM), time.Now())
+	l.tokens.level += float64(reserved)
+	if l.tokens.level > float64(endpoint.TPM) {
+		l.tokens.level = float64(endpoint.TPM)
+	}
+	logger.Debug("[%s] TPM refunded: %d reserved tokens", endpoint.Name, reserved)
+}
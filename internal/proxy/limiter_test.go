@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		level     float64 // level after the first refill, before time passes
+		elapsed   time.Duration
+		take      float64
+		wantLevel float64
+		wantAllow bool
+		wantWait  time.Duration
+	}{
+		{name: "full bucket", level: 60, take: 10, wantLevel: 60, wantAllow: true},
+		{name: "refill is capped at the limit", level: 50, elapsed: time.Minute, take: 10, wantLevel: 60, wantAllow: true},
+		{name: "partial refill", level: 0, elapsed: 10 * time.Second, take: 10, wantLevel: 10, wantAllow: true},
+		{name: "empty bucket waits", level: 0, elapsed: 5 * time.Second, take: 10, wantLevel: 5, wantWait: 5 * time.Second},
+		{name: "overdrawn bucket waits longer", level: -30, take: 10, wantLevel: -30, wantWait: 40 * time.Second},
+		{name: "oversized request waits for a full bucket", level: 30, take: 120, wantLevel: 30, wantWait: 30 * time.Second},
+		{name: "full bucket admits an oversized request", level: 60, take: 120, wantLevel: 60, wantAllow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const perMinute = 60
+			var b tokenBucket
+			b.refill(perMinute, start)
+			b.level = tt.level
+			b.refill(perMinute, start.Add(tt.elapsed))
+
+			if b.level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", b.level, tt.wantLevel)
+			}
+			if got := b.allows(tt.take, perMinute); got != tt.wantAllow {
+				t.Errorf("allows = %v, want %v", got, tt.wantAllow)
+			}
+			if got := b.wait(tt.take, perMinute); got != tt.wantWait {
+				t.Errorf("wait = %v, want %v", got, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestLimiterReserveAndSettle(t *testing.T) {
+	endpoint := config.Endpoint{Name: "limited", RPM: 10, TPM: 1000}
+
+	tests := []struct {
+		name       string
+		reserve    []int                        // token estimates of the attempts, in order
+		settle     func(p *Proxy, reserved int) // what happens to the last attempt
+		wantTokens float64                      // about what is left in the TPM bucket
+		check      func(p *Proxy) bool          // further condition that must hold, if any
+	}{
+		{name: "reservation holds until settled", reserve: []int{400},
+			settle: func(p *Proxy, reserved int) {}, wantTokens: 600},
+		{name: "reconcile charges actual usage", reserve: []int{400},
+			settle: func(p *Proxy, reserved int) { p.limiterReconcile(endpoint, reserved, 700) }, wantTokens: 300},
+		{name: "reconcile gives back unused tokens", reserve: []int{400},
+			settle: func(p *Proxy, reserved int) { p.limiterReconcile(endpoint, reserved, 100) }, wantTokens: 900},
+		{name: "unknown usage keeps the reservation", reserve: []int{400},
+			settle: func(p *Proxy, reserved int) { p.limiterReconcile(endpoint, reserved, 0) }, wantTokens: 600},
+		{name: "refund gives the whole reservation back", reserve: []int{400, 400},
+			settle: func(p *Proxy, reserved int) { p.limiterRefund(endpoint, reserved) }, wantTokens: 600},
+		{name: "refund does not overfill", reserve: []int{400},
+			settle: func(p *Proxy, reserved int) {
+				p.limiterRefund(endpoint, reserved)
+				p.limiterRefund(endpoint, reserved)
+			}, wantTokens: 1000},
+		{name: "exhausted budget is not reserved", reserve: []int{600, 600},
+			settle: func(p *Proxy, reserved int) {}, wantTokens: 400,
+			check: func(p *Proxy) bool { return p.limiterWait(endpoint, 600) > 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(&config.Config{}, &fakeStatsStorage{}, "test")
+			last := 0
+			for _, tokens := range tt.reserve {
+				if p.limiterReserve(endpoint, tokens) {
+					last = tokens
+				}
+			}
+			tt.settle(p, last)
+
+			l := p.getLimiter(endpoint.Name)
+			l.mu.Lock()
+			l.tokens.refill(float64(endpoint.TPM), time.Now())
+			level := l.tokens.level
+			l.mu.Unlock()
+			// Allow for the refill of the few microseconds the test takes
+			if level < tt.wantTokens || level > tt.wantTokens+1 {
+				t.Errorf("TPM bucket at %.1f, want %.0f", level, tt.wantTokens)
+			}
+			if tt.check != nil && !tt.check(p) {
+				t.Error("a second 600-token request would be admitted")
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the latency histograms
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// counterVec is a counter with labels
+type counterVec struct {
+	labels []string
+	values map[string]float64 // by joined label values
+}
+
+// histogram counts observations into cumulative buckets
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// histogramVec is a histogram with labels
+type histogramVec struct {
+	labels  []string
+	buckets []float64
+	series  map[string]*histogram // by joined label values
+}
+
+// labelSep joins label values into a map key
+const labelSep = "\x00"
+
+// newCounterVec creates a counter with the given label names
+func newCounterVec(labels ...string) *counterVec {
+	return &counterVec{labels: labels, values: make(map[string]float64)}
+}
+
+// newHistogramVec creates a histogram with the given buckets and label names
+func newHistogramVec(buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// add adds v to the counter with the given label values
+func (c *counterVec) add(v float64, labelValues ...string) {
+	c.values[strings.Join(labelValues, labelSep)] += v
+}
+
+// observe adds an observation to the histogram with the given label values
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, labelSep)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// metrics collects the Prometheus metrics of the proxy. Counters and histograms are fed
+// next to the stats hooks; gauges are read from the proxy state when scraped.
+type metrics struct {
+	mu         sync.Mutex
+	requests   *counterVec   // upstream responses by endpoint, client format and status
+	errors     *counterVec   // failed upstream attempts by endpoint
+	tokens     *counterVec   // tokens by endpoint and type
+	retries    *counterVec   // attempts that retried a request on another try, by endpoint
+	rotations  float64       // failover switches to the next endpoint
+	latency    *histogramVec // seconds until the upstream response headers, by endpoint
+	firstToken *histogramVec // seconds until the first streamed event, by endpoint
+}
+
+// newMetrics creates empty metrics
+func newMetrics() *metrics {
+	return &metrics{
+		requests:   newCounterVec("endpoint", "client_format", "status"),
+		errors:     newCounterVec("endpoint"),
+		tokens:     newCounterVec("endpoint", "type"),
+		retries:    newCounterVec("endpoint"),
+		latency:    newHistogramVec(latencyBuckets, "endpoint"),
+		firstToken: newHistogramVec(latencyBuckets, "endpoint"),
+	}
+}
+
+// recordResponse counts an upstream response (status 0 for a transport error) and its latency
+func (m *metrics) recordResponse(endpointName string, format ClientFormat, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statusLabel := "error"
+	if status > 0 {
+		statusLabel = strconv.Itoa(status)
+		m.latency.observe(latency.Seconds(), endpointName)
+	}
+	m.requests.add(1, endpointName, string(format), statusLabel)
+}
+
+// recordError counts a failed attempt against an endpoint
+func (m *metrics) recordError(endpointName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors.add(1, endpointName)
+}
+
+// recordTokens counts the tokens of a completed request
+func (m *metrics) recordTokens(endpointName string, inputTokens, outputTokens int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens.add(float64(inputTokens), endpointName, "input")
+	m.tokens.add(float64(outputTokens), endpointName, "output")
+}
+
+// recordCacheTokens counts the prompt cache tokens reported by an upstream
+func (m *metrics) recordCacheTokens(endpointName string, cache cacheUsage) {
+	if cache == (cacheUsage{}) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens.add(float64(cache.read), endpointName, "cache_read")
+	m.tokens.add(float64(cache.creation), endpointName, "cache_creation")
+}
+
+// recordRetry counts an attempt that retries a request
+func (m *metrics) recordRetry(endpointName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries.add(1, endpointName)
+}
+
+// recordRotation counts a failover switch to the next endpoint
+func (m *metrics) recordRotation() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rotations++
+}
+
+// recordFirstToken observes the time until the first event of a stream
+func (m *metrics) recordFirstToken(endpointName string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.firstToken.observe(d.Seconds(), endpointName)
+}
+
+// cacheUsage counts the prompt cache tokens of a response
+type cacheUsage struct {
+	read     int
+	creation int
+}
+
+// add reads the cache fields of an Anthropic usage object
+func (c *cacheUsage) add(usage map[string]interface{}) {
+	if v, ok := usage["cache_read_input_tokens"].(float64); ok {
+		c.read = int(v)
+	}
+	if v, ok := usage["cache_creation_input_tokens"].(float64); ok {
+		c.creation = int(v)
+	}
+}
+
+// handleMetrics serves the metrics in the Prometheus text format
+func (p *Proxy) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.writeMetrics(w)
+}
+
+// writeMetrics writes all metrics in the Prometheus text format
+func (p *Proxy) writeMetrics(w io.Writer) {
+	m := p.metrics
+	m.mu.Lock()
+	writeCounter(w, "ccnexus_requests_total", "Upstream responses by endpoint, client format and status (error for transport failures).", m.requests)
+	writeCounter(w, "ccnexus_upstream_errors_total", "Failed upstream attempts by endpoint.", m.errors)
+	writeCounter(w, "ccnexus_tokens_total", "Tokens by endpoint and type (input, output, cache_read, cache_creation).", m.tokens)
+	writeCounter(w, "ccnexus_retries_total", "Attempts that retried a request, by the endpoint retried on.", m.retries)
+	writeHeader(w, "ccnexus_endpoint_rotations_total", "Failover switches to the next endpoint.", "counter")
+	fmt.Fprintf(w, "ccnexus_endpoint_rotations_total %s\n", formatFloat(m.rotations))
+	writeHistogram(w, "ccnexus_upstream_latency_seconds", "Seconds until the upstream response headers arrived.", m.latency)
+	writeHistogram(w, "ccnexus_time_to_first_token_seconds", "Seconds until the first event of a streamed response.", m.firstToken)
+	m.mu.Unlock()
+
+	writeHeader(w, "ccnexus_hedged_requests_total", "Requests that were also sent to a second endpoint.", "counter")
+	fmt.Fprintf(w, "ccnexus_hedged_requests_total %d\n", p.stats.GetHedgedRequests())
+	writeHeader(w, "ccnexus_storage_write_errors_total", "Failed writes of statistics to the database.", "counter")
+	fmt.Fprintf(w, "ccnexus_storage_write_errors_total %d\n", p.stats.GetStorageErrors())
+
+	health := p.GetEndpointHealth()
+	writeHeader(w, "ccnexus_inflight_requests", "Requests in flight by endpoint.", "gauge")
+	for _, ep := range health {
+		fmt.Fprintf(w, "ccnexus_inflight_requests{endpoint=\"%s\"} %d\n", escapeLabel(ep.Name), ep.InFlight)
+	}
+	writeHeader(w, "ccnexus_queued_requests", "Requests waiting for a concurrency slot by endpoint.", "gauge")
+	for _, ep := range health {
+		fmt.Fprintf(w, "ccnexus_queued_requests{endpoint=\"%s\"} %d\n", escapeLabel(ep.Name), ep.Queued)
+	}
+	writeHeader(w, "ccnexus_endpoint_available", "Whether an enabled endpoint can take requests (1) or not (0).", "gauge")
+	for _, ep := range health {
+		available := 0
+		if ep.Status == EndpointAvailable {
+			available = 1
+		}
+		fmt.Fprintf(w, "ccnexus_endpoint_available{endpoint=\"%s\"} %d\n", escapeLabel(ep.Name), available)
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeCounter writes a counter with all its label values
+func writeCounter(w io.Writer, name, help string, c *counterVec) {
+	writeHeader(w, name, help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, formatLabels(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// writeHistogram writes the buckets, sum and count of a histogram for all its label values
+func writeHistogram(w io.Writer, name, help string, h *histogramVec) {
+	writeHeader(w, name, help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			le := `le="` + formatFloat(upper) + `"`
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(h.labels, key, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabels(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, formatLabels(h.labels, key, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, formatLabels(h.labels, key, ""), s.count)
+	}
+}
+
+// formatLabels renders label pairs from joined label values, with an optional extra pair
+func formatLabels(names []string, key, extra string) string {
+	values := strings.Split(key, labelSep)
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+`="`+escapeLabel(value)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+// formatFloat formats a sample value
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of a counter in a stable order
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// modelCache keeps the model lists fetched from providers, by API URL
+type modelCache struct {
+	mu     sync.RWMutex
+	models map[string][]string
+}
+
+// newModelCache creates an empty model cache
+func newModelCache() *modelCache {
+	return &modelCache{models: make(map[string][]string)}
+}
+
+// modelCacheKey normalizes an API URL the way model lists are fetched from it
+func modelCacheKey(apiURL string) string {
+	return normalizeAPIUrl(strings.TrimSuffix(strings.TrimSpace(apiURL), "/"))
+}
+
+// CacheModels remembers the models fetched from a provider so /v1/models can list them
+func (p *Proxy) CacheModels(apiURL string, models []string) {
+	p.models.mu.Lock()
+	defer p.models.mu.Unlock()
+	p.models.models[modelCacheKey(apiURL)] = append([]string(nil), models...)
+}
+
+// cachedModels returns the models last fetched from an API URL
+func (p *Proxy) cachedModels(apiURL string) []string {
+	p.models.mu.RLock()
+	defer p.models.mu.RUnlock()
+	return p.models.models[modelCacheKey(apiURL)]
+}
+
+// routableModel is a model clients can request and the endpoints that serve it
+type routableModel struct {
+	id        string
+	endpoints []string
+}
+
+// routableModels lists the model names a client may send to the enabled endpoints: their
+// default model, the literal aliases of their model maps, and the models fetched from their
+// providers that reach the upstream unchanged. Mapping targets are not listed, as a client
+// asking for one gets the endpoint's default model instead
+func (p *Proxy) routableModels(token *config.AccessToken) []routableModel {
+	byID := make(map[string]*routableModel)
+	add := func(id, endpoint string) {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return
+		}
+		m, ok := byID[id]
+		if !ok {
+			m = &routableModel{id: id}
+			byID[id] = m
+		}
+		for _, name := range m.endpoints {
+			if name == endpoint {
+				return
+			}
+		}
+		m.endpoints = append(m.endpoints, endpoint)
+	}
+
+	for _, ep := range p.getEnabledEndpoints() {
+		if token != nil && !token.AllowsEndpoint(ep.Name) {
+			continue
+		}
+		add(ep.Model, ep.Name)
+		for _, mapping := range ep.ModelMap {
+			// Glob patterns match many names and cannot be listed; literal ones are aliases
+			if !strings.ContainsAny(mapping.Pattern, "*?[") {
+				add(mapping.Pattern, ep.Name)
+			}
+		}
+		for _, id := range p.cachedModels(ep.APIUrl) {
+			// An empty target keeps the requested model
+			if target := ep.ResolveModel(id); target == "" || target == id {
+				add(id, ep.Name)
+			}
+		}
+	}
+
+	models := make([]routableModel, 0, len(byID))
+	for _, m := range byID {
+		models = append(models, *m)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].id < models[j].id })
+	return models
+}
+
+// modelsFormat picks Anthropic's list format for clients that send its version or key header
+// and OpenAI's for all others
+func modelsFormat(r *http.Request) ClientFormat {
+	if r.Header.Get("Anthropic-Version") != "" || r.Header.Get("X-Api-Key") != "" {
+		return ClientFormatClaude
+	}
+	return ClientFormatOpenAIChat
+}
+
+// modelCreated is the creation time reported for every model; the proxy does not know the real one
+var modelCreated = time.Unix(0, 0).UTC()
+
+// handleModels answers /v1/models and /v1/models/{id} with the routable models in the
+// Anthropic or OpenAI list format instead of forwarding the request to an endpoint
+func (p *Proxy) handleModels(w http.ResponseWriter, r *http.Request) {
+	format := modelsFormat(r)
+	if r.Method != http.MethodGet {
+		writeClientError(w, format, http.StatusMethodNotAllowed, "invalid_request_error", "invalid_request_error", "Method not allowed")
+		return
+	}
+	token, err := p.authenticate(r)
+	if err != nil {
+		logger.Warn("Rejected request from %s: %v", r.RemoteAddr, err)
+		writeClientError(w, format, http.StatusUnauthorized, "authentication_error", "invalid_api_key", err.Error())
+		return
+	}
+
+	models := p.routableModels(token)
+	if id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/models"), "/"); id != "" {
+		for _, m := range models {
+			if m.id == id {
+				writeModelsJSON(w, modelObject(format, m))
+				return
+			}
+		}
+		writeClientError(w, format, http.StatusNotFound, "not_found_error", "model_not_found", "model not found: "+id)
+		return
+	}
+
+	data := make([]map[string]interface{}, 0, len(models))
+	for _, m := range models {
+		data = append(data, modelObject(format, m))
+	}
+	if format != ClientFormatClaude {
+		writeModelsJSON(w, map[string]interface{}{"object": "list", "data": data})
+		return
+	}
+	list := map[string]interface{}{"data": data, "has_more": false, "first_id": nil, "last_id": nil}
+	if len(models) > 0 {
+		list["first_id"] = models[0].id
+		list["last_id"] = models[len(models)-1].id
+	}
+	writeModelsJSON(w, list)
+}
+
+// modelObject renders one model in the Anthropic or OpenAI format, plus the endpoints serving it
+func modelObject(format ClientFormat, m routableModel) map[string]interface{} {
+	if format == ClientFormatClaude {
+		return map[string]interface{}{
+			"type":         "model",
+			"id":           m.id,
+			"display_name": m.id,
+			"created_at":   modelCreated.Format(time.RFC3339),
+			"endpoints":    m.endpoints,
+		}
+	}
+	return map[string]interface{}{
+		"id":        m.id,
+		"object":    "model",
+		"created":   modelCreated.Unix(),
+		"owned_by":  "ccnexus",
+		"endpoints": m.endpoints,
+	}
+}
+
+// writeModelsJSON writes a models response
+func writeModelsJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
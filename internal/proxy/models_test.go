@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+func TestRoutableModels(t *testing.T) {
+	const apiURL = "https://api.example.com"
+
+	tests := []struct {
+		name    string
+		ep      config.Endpoint
+		fetched []string
+		want    []string
+	}{
+		{name: "default model", ep: config.Endpoint{Model: "gpt-4o"}, want: []string{"gpt-4o"}},
+		{name: "literal aliases but not their targets",
+			ep:   config.Endpoint{Model: "gpt-4o", ModelMap: []config.ModelMapping{{Pattern: "claude-sonnet-4", Model: "gpt-4.1"}}},
+			want: []string{"claude-sonnet-4", "gpt-4o"}},
+		{name: "glob patterns are not listed",
+			ep:   config.Endpoint{Model: "gpt-4o", ModelMap: []config.ModelMapping{{Pattern: "*haiku*", Model: "gpt-4o-mini"}}},
+			want: []string{"gpt-4o"}},
+		{name: "fetched models are hidden behind a default model",
+			ep: config.Endpoint{Model: "gpt-4o"}, fetched: []string{"gpt-4o", "gpt-4.1"}, want: []string{"gpt-4o"}},
+		{name: "fetched models pass through without a default model",
+			ep: config.Endpoint{}, fetched: []string{"claude-opus-4", "claude-sonnet-4"}, want: []string{"claude-opus-4", "claude-sonnet-4"}},
+		{name: "fetched model shadowed by a mapping",
+			ep:      config.Endpoint{ModelMap: []config.ModelMapping{{Pattern: "*haiku*", Model: "claude-sonnet-4"}}},
+			fetched: []string{"claude-haiku-4", "claude-sonnet-4"}, want: []string{"claude-sonnet-4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := tt.ep
+			ep.Name, ep.APIUrl, ep.Enabled = "ep", apiURL, true
+			p := New(&config.Config{Endpoints: []config.Endpoint{ep}}, &fakeStatsStorage{}, "test")
+			p.CacheModels(apiURL, tt.fetched)
+
+			var got []string
+			for _, m := range p.routableModels(nil) {
+				got = append(got, m.id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("models = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
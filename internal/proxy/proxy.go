@@ -1,17 +1,22 @@
 package proxy
 
 import (
+	"container/list"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/lich0821/ccNexus/internal/capture"
 	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
+	"github.com/lich0821/ccNexus/internal/tracing"
 )
 
 // SSEEvent represents a Server-Sent Event
@@ -38,8 +43,26 @@ type Proxy struct {
 	currentIndex     int
 	mu               sync.RWMutex
 	server           *http.Server
-	activeRequests   map[string]bool              // tracks active requests by endpoint name
-	activeRequestsMu sync.RWMutex                 // protects activeRequests map
+	activeRequests   map[string]int               // number of active requests by endpoint name
+	activeRequestsMu sync.RWMutex                 // protects activeRequests and slotQueues
+	slotQueues       map[string]*list.List        // requests waiting for a concurrency slot by endpoint name
+	strategy         Strategy                     // endpoint selection strategy
+	strategyMu       sync.Mutex                   // protects strategy
+	breakers         map[string]*circuitBreaker   // circuit breaker per endpoint
+	breakersMu       sync.Mutex                   // protects breakers map
+	cooldowns        map[string]time.Time         // rate limit cooldown deadline per endpoint
+	cooldownsMu      sync.Mutex                   // protects cooldowns map
+	limiters         map[string]*endpointLimiter  // RPM/TPM token buckets per endpoint
+	limitersMu       sync.Mutex                   // protects limiters map
+	keyPools         map[string]*keyPool          // API key rotation state per endpoint
+	keyPoolsMu       sync.Mutex                   // protects keyPools map
+	affinity         *affinityCache               // conversation to endpoint bindings for sticky routing
+	transports       *transportManager            // pooled upstream transports per endpoint
+	metrics          *metrics                     // Prometheus counters and histograms
+	tracer           *tracing.Tracer              // OpenTelemetry span export
+	requestLog       *requestLogger               // per-request rows written to storage
+	capture          *capture.Recorder            // full request/response captures for debugging
+	models           *modelCache                  // model lists fetched from providers
 	endpointCtx      map[string]context.Context   // context per endpoint for cancellation
 	endpointCancel   map[string]context.CancelFunc // cancel functions per endpoint
 	ctxMu            sync.RWMutex                 // protects context maps
@@ -50,14 +73,29 @@ type Proxy struct {
 func New(cfg *config.Config, statsStorage StatsStorage, deviceID string) *Proxy {
 	stats := NewStats(statsStorage, deviceID)
 
-	return &Proxy{
+	p := &Proxy{
 		config:         cfg,
 		stats:          stats,
 		currentIndex:   0,
-		activeRequests: make(map[string]bool),
+		activeRequests: make(map[string]int),
+		slotQueues:     make(map[string]*list.List),
+		breakers:       make(map[string]*circuitBreaker),
+		cooldowns:      make(map[string]time.Time),
+		limiters:       make(map[string]*endpointLimiter),
+		keyPools:       make(map[string]*keyPool),
+		affinity:       newAffinityCache(),
+		transports:     newTransportManager(),
+		metrics:        newMetrics(),
+		tracer:         tracing.NewTracer(),
+		models:         newModelCache(),
 		endpointCtx:    make(map[string]context.Context),
 		endpointCancel: make(map[string]context.CancelFunc),
 	}
+	p.requestLog = newRequestLogger(statsStorage, stats, func() config.RequestLogConfig {
+		return p.config.GetRequestLog()
+	})
+	p.capture = capture.NewRecorder("", p.captureSecrets)
+	return p
 }
 
 // SetOnEndpointSuccess sets the callback for successful endpoint requests
@@ -84,7 +122,11 @@ func (p *Proxy) StartWithMux(customMux *http.ServeMux) error {
 	// Register proxy routes
 	mux.HandleFunc("/", p.handleProxy)
 	mux.HandleFunc("/v1/messages/count_tokens", p.handleCountTokens)
+	mux.HandleFunc("/v1/models", p.handleModels)
+	mux.HandleFunc("/v1/models/", p.handleModels)
 	mux.HandleFunc("/health", p.handleHealth)
+	mux.HandleFunc("/ready", p.handleReady)
+	mux.HandleFunc("/metrics", p.handleMetrics)
 	mux.HandleFunc("/stats", p.handleStats)
 
 	p.server = &http.Server{
@@ -100,6 +142,9 @@ func (p *Proxy) StartWithMux(customMux *http.ServeMux) error {
 
 // Stop stops the proxy server
 func (p *Proxy) Stop() error {
+	p.tracer.Shutdown()
+	p.requestLog.close()
+	p.capture.Close()
 	if p.server != nil {
 		return p.server.Close()
 	}
@@ -133,27 +178,52 @@ func (p *Proxy) getCurrentEndpoint() config.Endpoint {
 	return endpoints[index]
 }
 
-// markRequestActive marks an endpoint as having active requests
-func (p *Proxy) markRequestActive(endpointName string) {
-	p.activeRequestsMu.Lock()
-	defer p.activeRequestsMu.Unlock()
-	p.activeRequests[endpointName] = true
-}
-
-// markRequestInactive marks an endpoint as having no active requests
+// markRequestInactive records that an active request on an endpoint has finished,
+// handing its concurrency slot to the next queued request if there is one
 func (p *Proxy) markRequestInactive(endpointName string) {
 	p.activeRequestsMu.Lock()
 	defer p.activeRequestsMu.Unlock()
-	delete(p.activeRequests, endpointName)
+	if p.handOverSlot(endpointName) {
+		return
+	}
+	if p.activeRequests[endpointName] <= 1 {
+		delete(p.activeRequests, endpointName)
+		return
+	}
+	p.activeRequests[endpointName]--
 }
 
 // hasActiveRequests checks if an endpoint has active requests
 func (p *Proxy) hasActiveRequests(endpointName string) bool {
+	return p.inFlight(endpointName) > 0
+}
+
+// inFlight returns the number of active requests on an endpoint
+func (p *Proxy) inFlight(endpointName string) int {
 	p.activeRequestsMu.RLock()
 	defer p.activeRequestsMu.RUnlock()
 	return p.activeRequests[endpointName]
 }
 
+// getStrategy returns the configured selection strategy, rebuilding it when the config changed
+func (p *Proxy) getStrategy() Strategy {
+	name := p.config.GetLoadBalancing()
+
+	p.strategyMu.Lock()
+	defer p.strategyMu.Unlock()
+
+	if p.strategy == nil || (p.strategy.Name() != name && IsValidStrategy(name)) {
+		p.strategy = newStrategy(name, p)
+		logger.Debug("[STRATEGY] Using %s", p.strategy.Name())
+	}
+	return p.strategy
+}
+
+// GetStrategyName returns the name of the active selection strategy
+func (p *Proxy) GetStrategyName() string {
+	return p.getStrategy().Name()
+}
+
 // isCurrentEndpoint checks if the given endpoint is still the current one
 func (p *Proxy) isCurrentEndpoint(endpointName string) bool {
 	current := p.getCurrentEndpoint()
@@ -227,6 +297,7 @@ func (p *Proxy) rotateEndpoint() config.Endpoint {
 	p.currentIndex = (oldIndex + 1) % len(endpoints)
 
 	newEndpoint := endpoints[p.currentIndex]
+	p.metrics.recordRotation()
 	logger.Debug("[SWITCH] %s → %s (#%d)", oldEndpoint.Name, newEndpoint.Name, p.currentIndex+1)
 
 	return newEndpoint
@@ -290,20 +361,111 @@ func detectClientFormat(path string) ClientFormat {
 
 // handleProxy handles the main proxy logic
 func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+
+	// One trace per request, joining the client's trace if it sent a traceparent
+	p.tracer.Configure(p.config.GetTracing())
+	ctx, reqSpan := p.tracer.StartRoot(r.Context(), "proxy.request", r.Header)
+	if reqSpan != nil {
+		r = r.WithContext(ctx)
+		defer func() {
+			reqSpan.SetAttribute("http.response.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				reqSpan.SetError(http.StatusText(sw.status))
+			}
+			reqSpan.End()
+		}()
+		reqSpan.SetAttribute("http.request.method", r.Method)
+		reqSpan.SetAttribute("url.path", r.URL.Path)
+	}
+
+	// Detect client format
+	clientFormat := detectClientFormat(r.URL.Path)
+
+	// Every request carries one ID through the logs, the upstream requests and the response
+	reqID := requestID(r)
+	w.Header().Set(requestIDHeader, reqID)
+	reqSpan.SetAttribute("ccnexus.request_id", reqID)
+	reqLog := logger.WithFields(logger.Fields{"request_id": reqID, "client_format": string(clientFormat)})
+
+	token, ok := p.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	// Every authenticated request leaves one row in the request log
+	rec := RequestRecord{Timestamp: started, RequestID: reqID, ClientFormat: string(clientFormat)}
+	if token != nil {
+		rec.Client = token.Name
+	}
+	defer func() {
+		rec.Status = sw.status
+		rec.LatencyMs = time.Since(started).Milliseconds()
+		if rec.Streaming && sw.status == http.StatusOK {
+			rec.TTFTMs = sw.wroteAt.Sub(started).Milliseconds()
+		}
+		if sw.status == 0 && r.Context().Err() != nil {
+			rec.Error = "client closed the request"
+		}
+		p.requestLog.record(rec)
+	}()
+
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		logger.Error("Failed to read request body: %v", err)
+		rec.Error = err.Error()
+		reqLog.Error("Failed to read request body: %v", err)
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	// Detect client format
-	clientFormat := detectClientFormat(r.URL.Path)
+	reqLog.DebugLog("=== Proxy Request ===")
+	reqLog.DebugLog("Method: %s, Path: %s, ClientFormat: %s", r.Method, r.URL.Path, clientFormat)
+	reqLog.DebugLog("Request Body: %s", string(bodyBytes))
+
+	// Captured requests keep every attempt; endpoint captures are decided per attempt
+	p.capture.Configure(p.config.GetCapture())
+	pr := &proxyRequest{
+		p:            p,
+		w:            w,
+		r:            r,
+		clientFormat: clientFormat,
+		reqID:        reqID,
+		log:          reqLog,
+		span:         reqSpan,
+		token:        token,
+		rec:          &rec,
+		body:         bodyBytes,
+		captureAll:   p.capture.TakeRequest(),
+	}
+	defer func() {
+		for _, ex := range pr.captures {
+			p.capture.Record(ex)
+		}
+	}()
 
-	logger.DebugLog("=== Proxy Request ===")
-	logger.DebugLog("Method: %s, Path: %s, ClientFormat: %s", r.Method, r.URL.Path, clientFormat)
-	logger.DebugLog("Request Body: %s", string(bodyBytes))
+	// Clients over their quota are turned away before anything is sent upstream
+	if token != nil {
+		reason, resetAt, err := p.reserveQuota(*token, time.Now())
+		if err != nil {
+			reqLog.WithFields(logger.Fields{"client": token.Name}).Error("[%s] Quota check failed: %v", token.Name, err)
+			rec.Error = err.Error()
+			w.Header().Set("Retry-After", "5")
+			writeClientError(w, clientFormat, http.StatusServiceUnavailable, "api_error", "server_error", "Client quota cannot be checked right now")
+			return
+		}
+		if reason != "" {
+			reqLog.WithFields(logger.Fields{"client": token.Name}).Warn("[%s] %s", token.Name, reason)
+			rec.Error = reason
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(resetAt).Seconds()))))
+			writeClientError(w, clientFormat, http.StatusTooManyRequests, "rate_limit_error", "insufficient_quota", reason)
+			return
+		}
+		pr.clientResult.Errors = 1
+		defer func() { p.stats.RecordClient(token.Name, pr.clientResult) }()
+	}
 
 	var streamReq struct {
 		Model    string      `json:"model"`
@@ -311,192 +473,148 @@ func (p *Proxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 		Stream   bool        `json:"stream"`
 	}
 	json.Unmarshal(bodyBytes, &streamReq)
+	rec.Model = streamReq.Model
+	rec.Streaming = streamReq.Stream
+	reqSpan.SetAttribute("ccnexus.client_format", string(clientFormat))
+	reqSpan.SetAttribute("gen_ai.request.model", streamReq.Model)
+	if token != nil {
+		reqSpan.SetAttribute("ccnexus.client", token.Name)
+	}
 
 	endpoints := p.getEnabledEndpoints()
 	if len(endpoints) == 0 {
-		logger.Error("No enabled endpoints available")
+		reqLog.Error("No enabled endpoints available")
+		rec.Error = "no enabled endpoints"
 		http.Error(w, "No enabled endpoints configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	maxRetries := len(endpoints) * 2
-	endpointAttempts := 0
-	lastEndpointName := ""
-
-	for retry := 0; retry < maxRetries; retry++ {
-		endpoint := p.getCurrentEndpoint()
-		if endpoint.Name == "" {
-			http.Error(w, "No enabled endpoints available", http.StatusServiceUnavailable)
-			return
-		}
-
-		// Reset attempts counter if endpoint changed (e.g., manual switch)
-		if lastEndpointName != "" && lastEndpointName != endpoint.Name {
-			endpointAttempts = 0
-		}
-		lastEndpointName = endpoint.Name
+	// Routing rules narrow the endpoints down before any failover happens
+	endpoints, group := p.routeEndpoints(streamReq.Model, token)
+	if group != "" {
+		reqLog.Debug("[ROUTE] %s → group %s (%d endpoints)", streamReq.Model, group, len(endpoints))
+	}
+	if len(endpoints) == 0 {
+		reqLog.Error("No enabled endpoints in group %s for model %s", group, streamReq.Model)
+		rec.Error = "no enabled endpoints in group " + group
+		http.Error(w, fmt.Sprintf("No enabled endpoints available for model %s", streamReq.Model), http.StatusServiceUnavailable)
+		return
+	}
 
-		endpointAttempts++
-		p.markRequestActive(endpoint.Name)
-		p.stats.RecordRequest(endpoint.Name)
+	// Conversations stick to one endpoint per routing group to keep its prompt cache warm
+	convKey := conversationKey(r, bodyBytes)
+	if convKey != "" {
+		convKey = group + "/" + convKey
+	}
+	pr.model, pr.stream, pr.convKey = streamReq.Model, streamReq.Stream, convKey
 
-		trans, err := prepareTransformerForClient(clientFormat, endpoint)
-		if err != nil {
-			logger.Error("[%s] %v", endpoint.Name, err)
-			p.stats.RecordError(endpoint.Name)
-			p.markRequestInactive(endpoint.Name)
-			if endpointAttempts >= 2 {
-				p.rotateEndpoint()
-				endpointAttempts = 0
-			}
-			continue
+	pr.strategy = p.getStrategy()
+	// Two tries per endpoint, plus one for every additional API key it can rotate to
+	maxRetries := 0
+	for _, ep := range endpoints {
+		maxRetries += len(ep.Keys()) + 1
+	}
+	pr.attempts = make(map[string]int)
+	pr.exhausted = make(map[string]bool)
+
+	// Input tokens reserved against TPM limits, estimated only when an endpoint has one
+	for _, ep := range endpoints {
+		if ep.TPM > 0 {
+			pr.reservedTokens = p.estimateInputTokens(bodyBytes)
+			break
 		}
+	}
 
-		transformerName := trans.Name()
-
-		transformedBody, err := trans.TransformRequest(bodyBytes)
-		if err != nil {
-			logger.Error("[%s] Failed to transform request: %v", endpoint.Name, err)
-			p.stats.RecordError(endpoint.Name)
-			p.markRequestInactive(endpoint.Name)
-			if endpointAttempts >= 2 {
-				p.rotateEndpoint()
-				endpointAttempts = 0
-			}
-			continue
-		}
+	var waited time.Duration
+	backoffs := 0
+	retried := false // an earlier attempt of this request failed
 
-		logger.DebugLog("[%s] Transformer: %s", endpoint.Name, transformerName)
-		logger.DebugLog("[%s] Transformed Request: %s", endpoint.Name, string(transformedBody))
+	for retry := 0; retry < maxRetries; retry++ {
+		candidates, busy, routed := pr.eligible("")
 
-		cleanedBody, err := cleanIncompleteToolCalls(transformedBody)
-		if err != nil {
-			logger.Warn("[%s] Failed to clean tool calls: %v", endpoint.Name, err)
-			cleanedBody = transformedBody
+		hasSlot := false
+		endpoint, ok := p.stickyEndpoint(convKey, candidates)
+		if ok {
+			reqLog.Debug("[STICKY] Conversation stays on %s", endpoint.Name)
+		} else {
+			endpoint, ok = pr.strategy.Select(candidates)
 		}
-		transformedBody = cleanedBody
-
-		var thinkingEnabled bool
-		if strings.Contains(transformerName, "openai") {
-			var openaiReq map[string]interface{}
-			if err := json.Unmarshal(transformedBody, &openaiReq); err == nil {
-				if enable, ok := openaiReq["enable_thinking"].(bool); ok {
-					thinkingEnabled = enable
+		if !ok && len(busy) > 0 {
+			// Every usable endpoint is at its concurrency limit: queue for a slot
+			endpoint, _ = pr.strategy.Select(busy)
+			queue := p.config.GetConcurrency()
+			if !p.waitForSlot(r.Context(), endpoint, queue.QueueSize, time.Duration(queue.QueueTimeout)*time.Second) {
+				if r.Context().Err() != nil {
+					return
 				}
+				reqLog.WithFields(logger.Fields{"endpoint": endpoint.Name}).Warn("[%s] No concurrency slot available (limit %d)", endpoint.Name, endpoint.MaxConcurrent)
+				rec.Error = "no concurrency slot available"
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "All endpoints are at their concurrency limit", http.StatusServiceUnavailable)
+				return
 			}
+			hasSlot = true
+			ok = true
 		}
-
-		proxyReq, err := buildProxyRequest(r, endpoint, transformedBody, transformerName)
-		if err != nil {
-			logger.Error("[%s] Failed to create request: %v", endpoint.Name, err)
-			p.stats.RecordError(endpoint.Name)
-			p.markRequestInactive(endpoint.Name)
-			if endpointAttempts >= 2 {
-				p.rotateEndpoint()
-				endpointAttempts = 0
+		if !ok {
+			// Everything left is cooling down or out of RPM/TPM budget: wait it out with backoff
+			// instead of failing at once
+			wait := p.shortestWait(routed, pr.exhausted, pr.reservedTokens)
+			if wait <= 0 || waited+wait > maxCooldownWait {
+				break
 			}
-			continue
-		}
-
-		ctx := p.getEndpointContext(endpoint.Name)
-		resp, err := sendRequest(ctx, proxyReq, p.config)
-		if err != nil {
-			logger.Error("[%s] Request failed: %v", endpoint.Name, err)
-			p.stats.RecordError(endpoint.Name)
-			p.markRequestInactive(endpoint.Name)
-			if endpointAttempts >= 2 {
-				p.rotateEndpoint()
-				endpointAttempts = 0
+			delay := wait + backoffDelay(backoffs)
+			backoffs++
+			reqLog.Debug("All endpoints cooling down, retrying in %s", delay.Round(time.Millisecond))
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
 			}
+			waited += delay
+			retry-- // waiting does not use up an attempt
 			continue
 		}
 
-		contentType := resp.Header.Get("Content-Type")
-		isStreaming := contentType == "text/event-stream" || (streamReq.Stream && strings.Contains(contentType, "text/event-stream"))
-
-		if resp.StatusCode == http.StatusOK && isStreaming {
-			inputTokens, outputTokens, outputText := p.handleStreamingResponse(w, resp, endpoint, trans, transformerName, thinkingEnabled, streamReq.Model, bodyBytes)
-
-			// Fallback: estimate tokens when usage is 0
-			if inputTokens == 0 || outputTokens == 0 {
-				inputTokens, outputTokens = p.estimateTokens(bodyBytes, outputText, inputTokens, outputTokens, endpoint.Name)
-			}
-
-			p.stats.RecordTokens(endpoint.Name, inputTokens, outputTokens)
-			p.markRequestInactive(endpoint.Name)
-			if p.onEndpointSuccess != nil {
-				p.onEndpointSuccess(endpoint.Name)
-			}
-			logger.Debug("[%s] Request completed successfully (streaming)", endpoint.Name)
-			return
+		attempt, ok := pr.startAttempt(endpoint, hasSlot)
+		if !ok {
+			continue
 		}
-
-		if resp.StatusCode == http.StatusOK {
-			inputTokens, outputTokens, err := p.handleNonStreamingResponse(w, resp, endpoint, trans)
-			if err == nil {
-				p.stats.RecordTokens(endpoint.Name, inputTokens, outputTokens)
-				p.markRequestInactive(endpoint.Name)
-				if p.onEndpointSuccess != nil {
-					p.onEndpointSuccess(endpoint.Name)
-				}
-				logger.Debug("[%s] Request completed successfully", endpoint.Name)
-				return
-			}
+		if retried {
+			p.metrics.recordRetry(endpoint.Name)
 		}
-
-		if shouldRetry(resp.StatusCode) {
-			var errBody []byte
-			if resp.Header.Get("Content-Encoding") == "gzip" {
-				errBody, _ = decompressGzip(resp.Body)
-			} else {
-				errBody, _ = io.ReadAll(resp.Body)
+		retried = true
+
+		var resp *http.Response
+		if hedgeDelay := time.Duration(p.config.GetHedging().DelayMs) * time.Millisecond; hedgeDelay > 0 {
+			pr.primaryName = endpoint.Name
+			attempt, resp, err = p.sendHedged(attempt, hedgeDelay, pr.startHedge, pr.hedgeFailed)
+			if attempt.cancel != nil {
+				defer attempt.cancel()
 			}
-			resp.Body.Close()
-			errMsg := string(errBody)
-			if len(errMsg) > 200 {
-				errMsg = errMsg[:200] + "..."
-			}
-			logger.Warn("[%s] Request failed %d: %s", endpoint.Name, resp.StatusCode, errMsg)
-			logger.DebugLog("[%s] Request failed %d: %s", endpoint.Name, resp.StatusCode, errMsg)
-			p.stats.RecordError(endpoint.Name)
-			p.markRequestInactive(endpoint.Name)
-			if endpointAttempts >= 2 {
-				p.rotateEndpoint()
-				endpointAttempts = 0
-			}
-			continue
-		}
-
-		var respBody []byte
-		if resp.Header.Get("Content-Encoding") == "gzip" {
-			respBody, _ = decompressGzip(resp.Body)
 		} else {
-			respBody, _ = io.ReadAll(resp.Body)
+			resp, err = p.sendRequest(p.getEndpointContext(endpoint.Name), endpoint, attempt.proxyReq)
 		}
-		resp.Body.Close()
-		p.markRequestInactive(endpoint.Name)
-		// Log non-200 responses for debugging
-		if resp.StatusCode != http.StatusOK {
-			errMsg := string(respBody)
-			if len(errMsg) > 500 {
-				errMsg = errMsg[:500] + "..."
-			}
-			logger.Warn("[%s] Response %d: %s", endpoint.Name, resp.StatusCode, errMsg)
-			logger.DebugLog("[%s] Response %d: %s", endpoint.Name, resp.StatusCode, errMsg)
+		if pr.handleUpstream(attempt, resp, err) {
+			return
 		}
-		// Remove Content-Encoding header since we've decompressed
-		for key, values := range resp.Header {
-			if key == "Content-Encoding" || key == "Content-Length" {
-				continue
-			}
-			for _, value := range values {
-				w.Header().Add(key, value)
-			}
+	}
+
+	routed, _ := p.routeEndpoints(streamReq.Model, token)
+	if wait := p.shortestWait(routed, pr.exhausted, pr.reservedTokens); wait > 0 {
+		if rec.Error == "" {
+			rec.Error = "all endpoints are rate limited"
 		}
-		w.WriteHeader(resp.StatusCode)
-		w.Write(respBody)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "All endpoints are rate limited", http.StatusTooManyRequests)
 		return
 	}
 
+	if rec.Error == "" {
+		rec.Error = "all endpoints failed"
+	}
 	http.Error(w, "All endpoints failed", http.StatusServiceUnavailable)
 }
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// reserveQuota counts a request against the client's quota. It returns why the request is
+// rejected and when the exhausted quota resets, or "" if the request was counted and may
+// go ahead. If the usage so far cannot be loaded, clients with a quota are turned away
+// with the error rather than let through unchecked.
+func (p *Proxy) reserveQuota(token config.AccessToken, now time.Time) (string, time.Time, error) {
+	var reason string
+	var resetAt time.Time
+	_, err := p.stats.ReserveClient(token.Name, func(daily, monthly ClientUsage) bool {
+		reason, resetAt = quotaExceeded(token.Quota, daily, monthly, now)
+		return reason == ""
+	})
+	if err != nil {
+		if token.Quota != (config.ClientQuota{}) {
+			return "", time.Time{}, err
+		}
+		p.stats.RecordClient(token.Name, ClientUsage{Requests: 1})
+	}
+	return reason, resetAt, nil
+}
+
+// quotaExceeded checks a client's usage against its quota. It returns why the request is
+// rejected and when the exhausted quota resets, or "" if the request may go ahead.
+// Token quotas are checked against the tokens used so far, so the request that crosses
+// a token quota still completes.
+func quotaExceeded(q config.ClientQuota, daily, monthly ClientUsage, now time.Time) (string, time.Time) {
+	if q == (config.ClientQuota{}) {
+		return "", time.Time{}
+	}
+
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+
+	switch {
+	case q.MonthlyRequests > 0 && monthly.Requests >= q.MonthlyRequests:
+		return fmt.Sprintf("Monthly request quota of %d exceeded", q.MonthlyRequests), nextMonth
+	case q.MonthlyTokens > 0 && monthly.InputTokens+monthly.OutputTokens >= q.MonthlyTokens:
+		return fmt.Sprintf("Monthly token quota of %d exceeded", q.MonthlyTokens), nextMonth
+	case q.DailyRequests > 0 && daily.Requests >= q.DailyRequests:
+		return fmt.Sprintf("Daily request quota of %d exceeded", q.DailyRequests), tomorrow
+	case q.DailyTokens > 0 && daily.InputTokens+daily.OutputTokens >= q.DailyTokens:
+		return fmt.Sprintf("Daily token quota of %d exceeded", q.DailyTokens), tomorrow
+	}
+	return "", time.Time{}
+}
+
+// writeClientError answers with an error body in the client's API format: Anthropic's
+// {"type":"error","error":{...}} for Claude clients, OpenAI's {"error":{...}} otherwise
+func writeClientError(w http.ResponseWriter, format ClientFormat, status int, claudeType, openAIType, message string) {
+	var body interface{}
+	if format == ClientFormatClaude {
+		body = map[string]interface{}{
+			"type": "error",
+			"error": map[string]string{
+				"type":    claudeType,
+				"message": message,
+			},
+		}
+	} else {
+		body = map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": message,
+				"type":    openAIType,
+				"param":   nil,
+				"code":    openAIType,
+			},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
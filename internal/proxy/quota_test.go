@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+func TestQuotaExceeded(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.Local)
+	tomorrow := time.Date(2026, 3, 15, 0, 0, 0, 0, time.Local)
+	nextMonth := time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		quota     config.ClientQuota
+		daily     ClientUsage
+		monthly   ClientUsage
+		wantIn    string // part of the reason, "" for allowed
+		wantReset time.Time
+	}{
+		{name: "no quota", daily: ClientUsage{Requests: 1000}, monthly: ClientUsage{Requests: 1000}},
+		{name: "under daily requests", quota: config.ClientQuota{DailyRequests: 10}, daily: ClientUsage{Requests: 9}},
+		{name: "at daily requests", quota: config.ClientQuota{DailyRequests: 10}, daily: ClientUsage{Requests: 10},
+			wantIn: "Daily request", wantReset: tomorrow},
+		{name: "daily tokens count input and output", quota: config.ClientQuota{DailyTokens: 100},
+			daily: ClientUsage{InputTokens: 60, OutputTokens: 40}, wantIn: "Daily token", wantReset: tomorrow},
+		{name: "monthly requests", quota: config.ClientQuota{MonthlyRequests: 50}, monthly: ClientUsage{Requests: 50},
+			wantIn: "Monthly request", wantReset: nextMonth},
+		{name: "monthly wins over daily", quota: config.ClientQuota{DailyRequests: 1, MonthlyTokens: 10},
+			daily: ClientUsage{Requests: 1}, monthly: ClientUsage{OutputTokens: 10}, wantIn: "Monthly token", wantReset: nextMonth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, resetAt := quotaExceeded(tt.quota, tt.daily, tt.monthly, now)
+			if tt.wantIn == "" {
+				if reason != "" {
+					t.Errorf("rejected: %s", reason)
+				}
+				return
+			}
+			if !strings.Contains(reason, tt.wantIn) {
+				t.Errorf("reason = %q, want it to contain %q", reason, tt.wantIn)
+			}
+			if !resetAt.Equal(tt.wantReset) {
+				t.Errorf("resets at %v, want %v", resetAt, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestReserveQuotaStorageFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		quota     config.ClientQuota
+		wantErr   bool
+		wantDaily int
+	}{
+		{name: "client with a quota is turned away", quota: config.ClientQuota{DailyRequests: 10}, wantErr: true},
+		{name: "client without a quota goes ahead", wantDaily: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStatsStorage{failLoads: 1}
+			p := New(&config.Config{}, storage, "test")
+
+			reason, _, err := p.reserveQuota(config.AccessToken{Name: "ci", Quota: tt.quota}, time.Now())
+			if reason != "" || (err != nil) != tt.wantErr {
+				t.Errorf("reason %q, err %v; want error %v", reason, err, tt.wantErr)
+			}
+			if daily, _ := p.stats.GetClientUsage("ci"); daily.Requests != tt.wantDaily {
+				t.Errorf("counted %d requests, want %d", daily.Requests, tt.wantDaily)
+			}
+		})
+	}
+}
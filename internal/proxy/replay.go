@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lich0821/ccNexus/internal/capture"
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/transformer"
+)
+
+// ReplayOptions selects what a captured exchange is replayed against
+type ReplayOptions struct {
+	Endpoint string // endpoint to replay on, "" for the recorded one
+	Mock     bool   // transform the recorded upstream response instead of calling the endpoint
+}
+
+// ReplayResult is the outcome of a replay compared with the recorded exchange. Diffs are
+// unified diffs from the recorded to the replayed body, "" when they are equal.
+type ReplayResult struct {
+	RequestID           string   `json:"requestId"`
+	Endpoint            string   `json:"endpoint"`
+	Transformer         string   `json:"transformer"`
+	RecordedTransformer string   `json:"recordedTransformer"`
+	Mock                bool     `json:"mock"`
+	Request             string   `json:"request"`     // transformed request body
+	RequestDiff         string   `json:"requestDiff"` // against the recorded upstream request
+	Status              int      `json:"status,omitempty"`
+	Response            string   `json:"response,omitempty"` // response as the client would get it
+	ResponseDiff        string   `json:"responseDiff"`       // against the recorded client response
+	TransformErrors     []string `json:"transformErrors,omitempty"`
+	Error               string   `json:"error,omitempty"` // why the replay stopped early
+}
+
+// Replay runs the client request of a captured exchange through the current transformer
+// pipeline for an endpoint and compares the result with the recording. The response comes
+// from the endpoint, or with Mock from the recorded upstream response. Replays do not count
+// towards statistics or endpoint health.
+func (p *Proxy) Replay(ctx context.Context, ex *capture.Exchange, opts ReplayOptions) (*ReplayResult, error) {
+	if ex.ClientRequest == nil {
+		return nil, fmt.Errorf("capture has no client request")
+	}
+	name := opts.Endpoint
+	if name == "" {
+		name = ex.Endpoint
+	}
+	endpoint, ok := p.findEndpoint(name)
+	if !ok {
+		return nil, fmt.Errorf("endpoint %q not found", name)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, ex.ClientRequest.Method, ex.ClientRequest.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid captured request: %w", err)
+	}
+	// Redacted headers are left out rather than sent upstream as placeholders
+	for key, values := range ex.ClientRequest.Header {
+		for _, value := range values {
+			if value != capture.Redacted {
+				r.Header.Add(key, value)
+			}
+		}
+	}
+
+	clientFormat := ClientFormat(ex.ClientFormat)
+	if clientFormat == "" {
+		clientFormat = detectClientFormat(r.URL.Path)
+	}
+	body := []byte(ex.ClientRequest.Body)
+	var clientReq struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(body, &clientReq)
+
+	result := &ReplayResult{
+		RequestID:           ex.RequestID,
+		Endpoint:            endpoint.Name,
+		RecordedTransformer: ex.Transformer,
+		Mock:                opts.Mock,
+	}
+
+	key := ""
+	if keys := endpoint.Keys(); len(keys) > 0 {
+		key = keys[0]
+	}
+	attempt, err := prepareAttempt(r, clientFormat, endpoint, key, clientReq.Model, body)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Transformer = attempt.transformerName
+	result.Request = string(attempt.body)
+	if ex.UpstreamRequest != nil {
+		result.RequestDiff = capture.Diff(ex.UpstreamRequest.Body, result.Request)
+	}
+
+	var raw []byte
+	var contentType string
+	if opts.Mock {
+		if ex.UpstreamResponse == nil {
+			result.Error = "capture has no upstream response to replay"
+			return result, nil
+		}
+		result.Status = ex.UpstreamResponse.Status
+		contentType = ex.UpstreamResponse.Header.Get("Content-Type")
+		raw = []byte(ex.UpstreamResponse.Body)
+	} else {
+		resp, err := p.sendRequest(ctx, endpoint, attempt.proxyReq)
+		if err != nil {
+			result.Error = err.Error()
+			return result, nil
+		}
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			raw, err = decompressGzip(resp.Body)
+		} else {
+			raw, err = io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read response: %v", err)
+			return result, nil
+		}
+		result.Status = resp.StatusCode
+		contentType = resp.Header.Get("Content-Type")
+	}
+
+	// Like the proxy, only successful responses are transformed; errors pass through as they are
+	switch {
+	case result.Status != http.StatusOK:
+		result.Response = string(raw)
+	case strings.Contains(contentType, "text/event-stream"):
+		out, errs := p.transformStream(raw, attempt.trans, attempt.transformerName, clientReq.Model, body)
+		result.Response = string(out)
+		for _, err := range errs {
+			result.TransformErrors = append(result.TransformErrors, err.Error())
+		}
+	default:
+		out, err := attempt.trans.TransformResponse(raw, false)
+		if err != nil {
+			result.TransformErrors = append(result.TransformErrors, err.Error())
+		}
+		result.Response = string(out)
+	}
+	if ex.ClientResponse != nil {
+		result.ResponseDiff = capture.Diff(ex.ClientResponse.Body, result.Response)
+	}
+	return result, nil
+}
+
+// findEndpoint returns a configured endpoint by name, enabled or not
+func (p *Proxy) findEndpoint(name string) (config.Endpoint, bool) {
+	for _, ep := range p.config.GetEndpoints() {
+		if ep.Name == name {
+			return ep, true
+		}
+	}
+	return config.Endpoint{}, false
+}
+
+// transformStream transforms a complete SSE body event by event the way handleStreamingResponse
+// does, returning what the client would receive and the errors of events that failed
+func (p *Proxy) transformStream(raw []byte, trans transformer.Transformer, transformerName string, modelName string, bodyBytes []byte) ([]byte, []error) {
+	var streamCtx *transformer.StreamContext
+	switch transformerName {
+	case "cx_chat_openai", "cx_resp_openai2":
+		// Pure passthrough - no context needed
+	default:
+		streamCtx = transformer.NewStreamContext()
+		streamCtx.ModelName = modelName
+		streamCtx.InputTokens = p.estimateInputTokens(bodyBytes)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var out, buffer bytes.Buffer
+	var errs []error
+	started := false // events before the first data event are dropped, as in the proxy
+	for scanner.Scan() {
+		line := scanner.Text()
+		buffer.WriteString(line + "\n")
+
+		done := strings.Contains(line, "data: [DONE]")
+		if line != "" && !done {
+			continue
+		}
+		if !started && !done && !hasSSEData(buffer.Bytes()) {
+			buffer.Reset()
+			continue
+		}
+		started = true
+
+		transformedEvent, err := p.transformStreamEvent(buffer.Bytes(), trans, transformerName, streamCtx)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			out.Write(transformedEvent)
+		}
+		buffer.Reset()
+		if done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return out.Bytes(), errs
+}
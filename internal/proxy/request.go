@@ -6,12 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
-	"golang.org/x/net/proxy"
-
 	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
 	"github.com/lich0821/ccNexus/internal/transformer"
@@ -20,8 +17,9 @@ import (
 	"github.com/lich0821/ccNexus/internal/transformer/cx/responses"
 )
 
-// prepareTransformerForClient creates transformer based on client format and endpoint
-func prepareTransformerForClient(clientFormat ClientFormat, endpoint config.Endpoint) (transformer.Transformer, error) {
+// prepareTransformerForClient creates transformer based on client format and endpoint.
+// model is the target model resolved from the endpoint's model mapping for this request.
+func prepareTransformerForClient(clientFormat ClientFormat, endpoint config.Endpoint, model string) (transformer.Transformer, error) {
 	endpointTransformer := endpoint.Transformer
 	if endpointTransformer == "" {
 		endpointTransformer = "claude"
@@ -29,105 +27,103 @@ func prepareTransformerForClient(clientFormat ClientFormat, endpoint config.Endp
 
 	switch clientFormat {
 	case ClientFormatClaude:
-		return prepareCCTransformer(endpoint, endpointTransformer)
+		return prepareCCTransformer(endpoint, endpointTransformer, model)
 	case ClientFormatOpenAIChat:
-		return prepareCxChatTransformer(endpoint, endpointTransformer)
+		return prepareCxChatTransformer(endpoint, endpointTransformer, model)
 	case ClientFormatOpenAIResponses:
-		return prepareCxRespTransformer(endpoint, endpointTransformer)
+		return prepareCxRespTransformer(endpoint, endpointTransformer, model)
 	}
 
 	return nil, fmt.Errorf("unsupported client format: %s", clientFormat)
 }
 
 // prepareCCTransformer creates transformer for Claude Code client
-func prepareCCTransformer(endpoint config.Endpoint, endpointTransformer string) (transformer.Transformer, error) {
+func prepareCCTransformer(endpoint config.Endpoint, endpointTransformer string, model string) (transformer.Transformer, error) {
 	switch endpointTransformer {
 	case "claude":
-		if endpoint.Model != "" {
-			logger.Debug("[%s] Using cc_claude with model override: %s", endpoint.Name, endpoint.Model)
-			return cc.NewClaudeTransformerWithModel(endpoint.Model), nil
+		if model != "" {
+			logger.Debug("[%s] Using cc_claude with model override: %s", endpoint.Name, model)
+			return cc.NewClaudeTransformerWithModel(model), nil
 		}
 		return cc.NewClaudeTransformer(), nil
 	case "openai":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI transformer requires model field")
 		}
-		return cc.NewOpenAITransformer(endpoint.Model), nil
+		return cc.NewOpenAITransformer(model), nil
 	case "openai2":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI2 transformer requires model field")
 		}
-		return cc.NewOpenAI2Transformer(endpoint.Model), nil
+		return cc.NewOpenAI2Transformer(model), nil
 	case "gemini":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("Gemini transformer requires model field")
 		}
-		return cc.NewGeminiTransformer(endpoint.Model), nil
+		return cc.NewGeminiTransformer(model), nil
 	default:
 		return nil, fmt.Errorf("unsupported endpoint transformer: %s", endpointTransformer)
 	}
 }
 
 // prepareCxChatTransformer creates transformer for Codex Chat API client
-func prepareCxChatTransformer(endpoint config.Endpoint, endpointTransformer string) (transformer.Transformer, error) {
+func prepareCxChatTransformer(endpoint config.Endpoint, endpointTransformer string, model string) (transformer.Transformer, error) {
 	switch endpointTransformer {
 	case "claude":
-		model := endpoint.Model
 		if model == "" {
 			model = "claude-sonnet-4-20250514"
 		}
 		return chat.NewClaudeTransformer(model), nil
 	case "openai":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI transformer requires model field")
 		}
-		return chat.NewOpenAITransformer(endpoint.Model), nil
+		return chat.NewOpenAITransformer(model), nil
 	case "openai2":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI2 transformer requires model field")
 		}
-		return chat.NewOpenAI2Transformer(endpoint.Model), nil
+		return chat.NewOpenAI2Transformer(model), nil
 	case "gemini":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("Gemini transformer requires model field")
 		}
-		return chat.NewGeminiTransformer(endpoint.Model), nil
+		return chat.NewGeminiTransformer(model), nil
 	default:
 		return nil, fmt.Errorf("unsupported endpoint transformer for Codex Chat: %s", endpointTransformer)
 	}
 }
 
 // prepareCxRespTransformer creates transformer for Codex Responses API client
-func prepareCxRespTransformer(endpoint config.Endpoint, endpointTransformer string) (transformer.Transformer, error) {
+func prepareCxRespTransformer(endpoint config.Endpoint, endpointTransformer string, model string) (transformer.Transformer, error) {
 	switch endpointTransformer {
 	case "claude":
-		model := endpoint.Model
 		if model == "" {
 			model = "claude-sonnet-4-20250514"
 		}
 		return responses.NewClaudeTransformer(model), nil
 	case "openai":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI transformer requires model field")
 		}
-		return responses.NewOpenAITransformer(endpoint.Model), nil
+		return responses.NewOpenAITransformer(model), nil
 	case "openai2":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("OpenAI2 transformer requires model field")
 		}
-		return responses.NewOpenAI2Transformer(endpoint.Model), nil
+		return responses.NewOpenAI2Transformer(model), nil
 	case "gemini":
-		if endpoint.Model == "" {
+		if model == "" {
 			return nil, fmt.Errorf("Gemini transformer requires model field")
 		}
-		return responses.NewGeminiTransformer(endpoint.Model), nil
+		return responses.NewGeminiTransformer(model), nil
 	default:
 		return nil, fmt.Errorf("unsupported endpoint transformer for Codex Responses: %s", endpointTransformer)
 	}
 }
 
 // getTargetPath determines the target API path based on transformer name
-func getTargetPath(originalPath string, endpoint config.Endpoint, transformedBody []byte, transformerName string) string {
+func getTargetPath(originalPath string, model string, transformedBody []byte, transformerName string) string {
 	switch transformerName {
 	case "cc_claude", "cx_chat_claude", "cx_resp_claude":
 		return "/v1/messages"
@@ -141,16 +137,16 @@ func getTargetPath(originalPath string, endpoint config.Endpoint, transformedBod
 		}
 		json.Unmarshal(transformedBody, &geminiReq)
 		if geminiReq.Stream {
-			return fmt.Sprintf("/v1beta/models/%s:streamGenerateContent", endpoint.Model)
+			return fmt.Sprintf("/v1beta/models/%s:streamGenerateContent", model)
 		}
-		return fmt.Sprintf("/v1beta/models/%s:generateContent", endpoint.Model)
+		return fmt.Sprintf("/v1beta/models/%s:generateContent", model)
 	}
 	return originalPath
 }
 
 // buildProxyRequest creates an HTTP request for the target API
-func buildProxyRequest(r *http.Request, endpoint config.Endpoint, transformedBody []byte, transformerName string) (*http.Request, error) {
-	targetPath := getTargetPath(r.URL.Path, endpoint, transformedBody, transformerName)
+func buildProxyRequest(r *http.Request, endpoint config.Endpoint, model string, transformedBody []byte, transformerName string) (*http.Request, error) {
+	targetPath := getTargetPath(r.URL.Path, model, transformedBody, transformerName)
 	if targetPath == "" {
 		targetPath = r.URL.Path
 	}
@@ -179,21 +175,32 @@ func buildProxyRequest(r *http.Request, endpoint config.Endpoint, transformedBod
 	// Force gzip or no compression to avoid unsupported encodings (e.g., brotli)
 	proxyReq.Header.Set("Accept-Encoding", "gzip, identity")
 
-	// Set authentication based on transformer type
-	switch transformerName {
-	case "cc_openai", "cc_openai2", "cx_chat_openai", "cx_chat_openai2", "cx_resp_openai", "cx_resp_openai2":
-		proxyReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
-	case "cc_gemini", "cx_chat_gemini", "cx_resp_gemini":
-		q := proxyReq.URL.Query()
-		q.Set("key", endpoint.APIKey)
-		q.Set("alt", "sse")
-		proxyReq.URL.RawQuery = q.Encode()
-	default:
-		// Claude endpoints
-		proxyReq.Header.Set("x-api-key", endpoint.APIKey)
-		proxyReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+	// Set authentication based on transformer type unless the endpoint picks a style
+	if endpoint.AuthStyle != "" {
+		setAuth(proxyReq, endpoint)
+		if isGeminiTransformer(transformerName) {
+			q := proxyReq.URL.Query()
+			q.Set("alt", "sse")
+			proxyReq.URL.RawQuery = q.Encode()
+		}
+	} else {
+		switch transformerName {
+		case "cc_openai", "cc_openai2", "cx_chat_openai", "cx_chat_openai2", "cx_resp_openai", "cx_resp_openai2":
+			proxyReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+		case "cc_gemini", "cx_chat_gemini", "cx_resp_gemini":
+			q := proxyReq.URL.Query()
+			q.Set("key", endpoint.APIKey)
+			q.Set("alt", "sse")
+			proxyReq.URL.RawQuery = q.Encode()
+		default:
+			// Claude endpoints
+			proxyReq.Header.Set("x-api-key", endpoint.APIKey)
+			proxyReq.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+		}
 	}
 
+	applyHeaderRules(proxyReq.Header, endpoint.Headers)
+
 	// Set Host header
 	hostOnly := strings.TrimPrefix(strings.TrimPrefix(normalizedAPIUrl, "https://"), "http://")
 	proxyReq.Header.Set("Host", hostOnly)
@@ -201,55 +208,61 @@ func buildProxyRequest(r *http.Request, endpoint config.Endpoint, transformedBod
 	return proxyReq, nil
 }
 
-// sendRequest sends the HTTP request and returns the response
-func sendRequest(ctx context.Context, proxyReq *http.Request, cfg *config.Config) (*http.Response, error) {
-	proxyReq = proxyReq.WithContext(ctx)
-	client := &http.Client{
-		Timeout: 300 * time.Second,
+// clientAuthHeaders are the headers clients may carry their own credentials in
+var clientAuthHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key"}
+
+// setAuth sends the endpoint's API key in the endpoint's auth style. The credentials the
+// client sent are dropped first, so they never reach a gateway that looks elsewhere.
+func setAuth(req *http.Request, endpoint config.Endpoint) {
+	for _, name := range clientAuthHeaders {
+		req.Header.Del(name)
 	}
 
-	// Apply proxy if configured
-	if proxyCfg := cfg.GetProxy(); proxyCfg != nil && proxyCfg.URL != "" {
-		transport, err := CreateProxyTransport(proxyCfg.URL)
-		if err != nil {
-			logger.Warn("Failed to create proxy transport: %v, using direct connection", err)
-		} else {
-			client.Transport = transport
-			logger.Debug("Using proxy: %s", proxyCfg.URL)
-		}
+	switch endpoint.AuthStyle {
+	case config.AuthStyleBearer:
+		req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+	case config.AuthStyleAPIKey:
+		req.Header.Set("x-api-key", endpoint.APIKey)
+	case config.AuthStyleGoogAPIKey:
+		req.Header.Set("x-goog-api-key", endpoint.APIKey)
+	case config.AuthStyleQuery:
+		q := req.URL.Query()
+		q.Set("key", endpoint.APIKey)
+		req.URL.RawQuery = q.Encode()
+	case config.AuthStyleHeader:
+		req.Header.Set(endpoint.AuthHeader, endpoint.APIKey)
 	}
+}
 
-	return client.Do(proxyReq)
+// isGeminiTransformer checks if a transformer talks to the Gemini API
+func isGeminiTransformer(transformerName string) bool {
+	return strings.HasSuffix(transformerName, "_gemini")
 }
 
-// CreateProxyTransport creates an http.Transport with proxy support
-func CreateProxyTransport(proxyURL string) (*http.Transport, error) {
-	parsed, err := url.Parse(proxyURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+// applyHeaderRules adds, overrides or strips upstream headers in rule order
+func applyHeaderRules(header http.Header, rules []config.HeaderRule) {
+	for _, rule := range rules {
+		switch rule.Action {
+		case config.HeaderSet:
+			header.Set(rule.Name, rule.Value)
+		case config.HeaderAdd:
+			header.Add(rule.Name, rule.Value)
+		case config.HeaderRemove:
+			header.Del(rule.Name)
+		}
 	}
+}
 
-	transport := &http.Transport{}
+// defaultRequestTimeout bounds a whole upstream request, including the stream, unless
+// the endpoint sets its own total timeout
+const defaultRequestTimeout = 300 * time.Second
 
-	switch parsed.Scheme {
-	case "socks5", "socks5h":
-		auth := &proxy.Auth{}
-		if parsed.User != nil {
-			auth.User = parsed.User.Username()
-			auth.Password, _ = parsed.User.Password()
-		} else {
-			auth = nil
-		}
-		dialer, err := proxy.SOCKS5("tcp", parsed.Host, auth, proxy.Direct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
-		}
-		transport.Dial = dialer.Dial
-	case "http", "https":
-		transport.Proxy = http.ProxyURL(parsed)
-	default:
-		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsed.Scheme)
+// sendRequest sends the HTTP request over the endpoint's pooled transport and returns the response
+func (p *Proxy) sendRequest(ctx context.Context, endpoint config.Endpoint, proxyReq *http.Request) (*http.Response, error) {
+	proxyReq = proxyReq.WithContext(ctx)
+	client := &http.Client{
+		Transport: p.transportFor(endpoint),
+		Timeout:   endpointTotalTimeout(endpoint, defaultRequestTimeout),
 	}
-
-	return transport, nil
+	return client.Do(proxyReq)
 }
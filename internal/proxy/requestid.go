@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// requestIDHeader carries the ID of a proxy request to the upstream and back to the client
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID taken over from a client
+const maxRequestIDLength = 128
+
+// requestID returns the ID of a proxy request: the client's X-Request-ID if it is a plain
+// token, otherwise a new random one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID checks that a client's request ID is safe to log and forward
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+const (
+	// requestLogQueueSize is the number of records waiting to be written; more are dropped
+	requestLogQueueSize = 4096
+	// requestLogBatchSize is the largest number of records written in one transaction
+	requestLogBatchSize = 200
+	// requestLogFlushInterval is the longest time a record waits to be written
+	requestLogFlushInterval = 2 * time.Second
+	// requestLogPruneInterval is how often rows past the retention are deleted
+	requestLogPruneInterval = time.Hour
+)
+
+// RequestRecord is one proxied request as kept in the request log
+type RequestRecord struct {
+	Timestamp    time.Time
+	RequestID    string
+	Endpoint     string
+	Model        string
+	ClientFormat string
+	Client       string
+	Status       int
+	Attempts     int
+	LatencyMs    int64
+	TTFTMs       int64
+	InputTokens  int
+	OutputTokens int
+	Streaming    bool
+	Error        string
+}
+
+// requestLogger writes request records to storage in batches from a background goroutine
+// and prunes the rows past the retention
+type requestLogger struct {
+	storage  StatsStorage
+	stats    *Stats
+	settings func() config.RequestLogConfig
+	queue    chan RequestRecord
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  int64 // records dropped because the queue was full, since the last write
+}
+
+// newRequestLogger starts a request logger
+func newRequestLogger(storage StatsStorage, stats *Stats, settings func() config.RequestLogConfig) *requestLogger {
+	l := &requestLogger{
+		storage:  storage,
+		stats:    stats,
+		settings: settings,
+		queue:    make(chan RequestRecord, requestLogQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// record queues a record unless the request log is disabled; it never blocks the request
+func (l *requestLogger) record(rec RequestRecord) {
+	if !l.settings().Enabled {
+		return
+	}
+	select {
+	case <-l.stop:
+		return
+	default:
+	}
+	select {
+	case l.queue <- rec:
+	default:
+		atomic.AddInt64(&l.dropped, 1)
+	}
+}
+
+// close writes the queued records and stops the background goroutine
+func (l *requestLogger) close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+// run writes full batches right away and partial ones every flush interval
+func (l *requestLogger) run() {
+	defer close(l.done)
+
+	flushTicker := time.NewTicker(requestLogFlushInterval)
+	defer flushTicker.Stop()
+	pruneTicker := time.NewTicker(requestLogPruneInterval)
+	defer pruneTicker.Stop()
+
+	l.prune()
+
+	batch := make([]RequestRecord, 0, requestLogBatchSize)
+	for {
+		select {
+		case rec := <-l.queue:
+			batch = append(batch, rec)
+			if len(batch) >= requestLogBatchSize {
+				l.write(batch)
+				batch = batch[:0]
+			}
+		case <-flushTicker.C:
+			l.write(batch)
+			batch = batch[:0]
+		case <-pruneTicker.C:
+			l.prune()
+		case <-l.stop:
+			for {
+				select {
+				case rec := <-l.queue:
+					batch = append(batch, rec)
+					if len(batch) >= requestLogBatchSize {
+						l.write(batch)
+						batch = batch[:0]
+					}
+				default:
+					l.write(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// write stores a batch of records
+func (l *requestLogger) write(batch []RequestRecord) {
+	if dropped := atomic.SwapInt64(&l.dropped, 0); dropped > 0 {
+		logger.Warn("[REQUEST LOG] Queue full, dropped %d records", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := l.storage.RecordRequestLogs(batch); err != nil {
+		atomic.AddInt64(&l.stats.storageErrors, 1)
+		logger.Error("Failed to write %d request log records: %v", len(batch), err)
+	}
+}
+
+// prune deletes the rows older than the retention
+func (l *requestLogger) prune() {
+	days := l.settings().RetentionDays
+	if days <= 0 {
+		return
+	}
+	removed, err := l.storage.PruneRequestLogs(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		atomic.AddInt64(&l.stats.storageErrors, 1)
+		logger.Error("Failed to prune request log: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Debug("[REQUEST LOG] Pruned %d records older than %d days", removed, days)
+	}
+}
+
+// statusWriter remembers the status code of a response and when its headers were written
+type statusWriter struct {
+	http.ResponseWriter
+	status  int       // 0 until the headers are written
+	wroteAt time.Time // when the headers were written
+}
+
+// WriteHeader records the status code before writing it
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.wroteAt = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 of a body written without headers
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so streamed responses still reach the client event by event
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
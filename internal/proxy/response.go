@@ -2,16 +2,18 @@ package proxy
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/lich0821/ccNexus/internal/capture"
 	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
 	"github.com/lich0821/ccNexus/internal/transformer"
 )
 
 // handleNonStreamingResponse processes non-streaming responses
-func (p *Proxy) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Response, endpoint config.Endpoint, trans transformer.Transformer) (int, int, error) {
+func (p *Proxy) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Response, endpoint config.Endpoint, trans transformer.Transformer, ex *capture.Exchange) (int, int, error) {
 	var bodyBytes []byte
 	var err error
 
@@ -29,6 +31,7 @@ func (p *Proxy) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Res
 		}
 	}
 	resp.Body.Close()
+	ex.AppendUpstreamBody(bodyBytes)
 
 	logger.DebugLog("[%s] Response Body: %s", endpoint.Name, string(bodyBytes))
 
@@ -36,13 +39,15 @@ func (p *Proxy) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Res
 	transformedResp, err := trans.TransformResponse(bodyBytes, false)
 	if err != nil {
 		logger.Error("[%s] Failed to transform response: %v", endpoint.Name, err)
+		ex.SetError(fmt.Sprintf("failed to transform response: %v", err))
 		return 0, 0, err
 	}
 
 	logger.DebugLog("[%s] Transformed Response: %s", endpoint.Name, string(transformedResp))
 
 	// Extract token usage
-	inputTokens, outputTokens := extractTokenUsage(transformedResp)
+	inputTokens, outputTokens, cache := extractTokenUsage(transformedResp)
+	p.metrics.recordCacheTokens(endpoint.Name, cache)
 
 	// Copy response headers
 	for key, values := range resp.Header {
@@ -56,18 +61,21 @@ func (p *Proxy) handleNonStreamingResponse(w http.ResponseWriter, resp *http.Res
 
 	w.WriteHeader(resp.StatusCode)
 	w.Write(transformedResp)
+	ex.SetClientResponse(resp.StatusCode, w.Header())
+	ex.AppendClientBody(transformedResp)
 
 	return inputTokens, outputTokens, nil
 }
 
 // extractTokenUsage extracts token counts from response
-func extractTokenUsage(responseBody []byte) (int, int) {
+func extractTokenUsage(responseBody []byte) (int, int, cacheUsage) {
 	var resp map[string]interface{}
 	if err := json.Unmarshal(responseBody, &resp); err != nil {
-		return 0, 0
+		return 0, 0, cacheUsage{}
 	}
 
 	var inputTokens, outputTokens int
+	var cache cacheUsage
 
 	if usage, ok := resp["usage"].(map[string]interface{}); ok {
 		if input, ok := usage["input_tokens"].(float64); ok {
@@ -76,7 +84,8 @@ func extractTokenUsage(responseBody []byte) (int, int) {
 		if output, ok := usage["output_tokens"].(float64); ok {
 			outputTokens = int(output)
 		}
+		cache.add(usage)
 	}
 
-	return inputTokens, outputTokens
+	return inputTokens, outputTokens, cache
 }
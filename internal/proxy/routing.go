@@ -0,0 +1,46 @@
+package proxy
+
+import "github.com/lich0821/ccNexus/internal/config"
+
+// matchRoutingRule returns the first rule whose pattern matches the model (case-insensitive)
+func matchRoutingRule(rules []config.RoutingRule, model string) (config.RoutingRule, bool) {
+	if model == "" {
+		return config.RoutingRule{}, false
+	}
+
+	for _, rule := range rules {
+		if config.MatchModel(rule.Pattern, model) {
+			return rule, true
+		}
+	}
+	return config.RoutingRule{}, false
+}
+
+// routeEndpoints returns the enabled endpoints eligible for the requested model and the
+// group of the matching rule. Without a matching rule every enabled endpoint is eligible.
+// With an access token, only the endpoints it allows are considered.
+func (p *Proxy) routeEndpoints(model string, token *config.AccessToken) ([]config.Endpoint, string) {
+	endpoints := p.getEnabledEndpoints()
+	if token != nil {
+		allowed := make([]config.Endpoint, 0, len(endpoints))
+		for _, ep := range endpoints {
+			if token.AllowsEndpoint(ep.Name) {
+				allowed = append(allowed, ep)
+			}
+		}
+		endpoints = allowed
+	}
+
+	rule, ok := matchRoutingRule(p.config.GetRouting(), model)
+	if !ok {
+		return endpoints, ""
+	}
+
+	eligible := make([]config.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Group == rule.Group {
+			eligible = append(eligible, ep)
+		}
+	}
+	return eligible, rule.Group
+}
@@ -1,8 +1,12 @@
 package proxy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lich0821/ccNexus/internal/logger"
@@ -27,11 +31,32 @@ type EndpointStats struct {
 	DailyHistory map[string]*DailyStats `json:"dailyHistory"` // Key: date string (source of truth)
 }
 
+// EndpointActivity holds when an endpoint last answered successfully and last failed (since start)
+type EndpointActivity struct {
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   *time.Time `json:"lastError,omitempty"`
+}
+
+// KeyUsage counts the requests made with one API key of an endpoint
+type KeyUsage struct {
+	Requests     int `json:"requests"`
+	Errors       int `json:"errors"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
 // StatsStorage defines the interface for stats persistence
 type StatsStorage interface {
 	RecordDailyStat(stat interface{}) error
 	GetTotalStats() (int, map[string]interface{}, error)
 	GetDailyStats(endpointName, startDate, endDate string) ([]interface{}, error)
+	RecordClientStat(stat interface{}) error
+	GetClientStats(startDate, endDate string) ([]interface{}, error)
+	RecordKeyStat(stat interface{}) error
+	GetKeyStats() ([]interface{}, error)
+	RecordRequestLogs(records interface{}) error
+	PruneRequestLogs(before time.Time) (int64, error)
+	Ping() error
 }
 
 // StatRecord represents a stat record for storage
@@ -45,6 +70,46 @@ type StatRecord struct {
 	DeviceID     string
 }
 
+// ClientStatRecord represents a client usage record for storage
+type ClientStatRecord struct {
+	ClientName   string
+	Date         string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+	DeviceID     string
+}
+
+// KeyStatRecord represents an API key usage record for storage. The key itself is never
+// stored, only its hash.
+type KeyStatRecord struct {
+	EndpointName string
+	KeyHash      string
+	Date         string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+	DeviceID     string
+}
+
+// ClientUsage is the usage of one client in a period
+type ClientUsage struct {
+	Requests     int `json:"requests"`
+	Errors       int `json:"errors"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// add adds delta to the usage
+func (u *ClientUsage) add(delta ClientUsage) {
+	u.Requests += delta.Requests
+	u.Errors += delta.Errors
+	u.InputTokens += delta.InputTokens
+	u.OutputTokens += delta.OutputTokens
+}
+
 // StatsData represents aggregated stats data
 type StatsData struct {
 	Requests     int
@@ -74,6 +139,17 @@ type Stats struct {
 	saveMu        sync.Mutex
 	saveDebounce  time.Duration
 	lastSaveError error
+
+	hedged        int64 // requests that were also sent to a second endpoint (since start)
+	storageErrors int64 // failed writes of stats to storage (since start)
+	keyUsage      map[string]*KeyUsage // per API key usage by endpoint and key hash, loaded from storage
+	keysLoaded    bool                 // whether keyUsage holds the usage from storage
+	activity      map[string]*EndpointActivity // last success and error by endpoint (since start)
+
+	// Client usage of the current day and month, loaded from storage for quota checks
+	clientDay     string // day the counters belong to
+	clientDaily   map[string]*ClientUsage
+	clientMonthly map[string]*ClientUsage
 }
 
 // NewStats creates a new Stats instance
@@ -82,6 +158,8 @@ func NewStats(storage StatsStorage, deviceID string) *Stats {
 		storage:      storage,
 		deviceID:     deviceID,
 		saveDebounce: 2 * time.Second, // Debounce save operations by 2 seconds
+		keyUsage:     make(map[string]*KeyUsage),
+		activity:     make(map[string]*EndpointActivity),
 	}
 }
 
@@ -100,12 +178,14 @@ func (s *Stats) RecordRequest(endpointName string) {
 	}
 
 	if err := s.storage.RecordDailyStat(stat); err != nil {
+		atomic.AddInt64(&s.storageErrors, 1)
 		logger.Error("Failed to record request: %v", err)
 	}
 }
 
 // RecordError records an error for an endpoint
 func (s *Stats) RecordError(endpointName string) {
+	s.recordActivity(endpointName, false)
 	date := time.Now().Format("2006-01-02")
 
 	stat := &StatRecord{
@@ -119,6 +199,7 @@ func (s *Stats) RecordError(endpointName string) {
 	}
 
 	if err := s.storage.RecordDailyStat(stat); err != nil {
+		atomic.AddInt64(&s.storageErrors, 1)
 		logger.Error("Failed to record error: %v", err)
 	}
 }
@@ -138,10 +219,272 @@ func (s *Stats) RecordTokens(endpointName string, inputTokens, outputTokens int)
 	}
 
 	if err := s.storage.RecordDailyStat(stat); err != nil {
+		atomic.AddInt64(&s.storageErrors, 1)
 		logger.Error("Failed to record tokens: %v", err)
 	}
 }
 
+// RecordSuccess records that an endpoint answered a request successfully
+func (s *Stats) RecordSuccess(endpointName string) {
+	s.recordActivity(endpointName, true)
+}
+
+// recordActivity stamps the last success or error of an endpoint with the current time
+func (s *Stats) recordActivity(endpointName string, success bool) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity, ok := s.activity[endpointName]
+	if !ok {
+		activity = &EndpointActivity{}
+		s.activity[endpointName] = activity
+	}
+	if success {
+		activity.LastSuccess = &now
+	} else {
+		activity.LastError = &now
+	}
+}
+
+// GetActivity returns when an endpoint last succeeded and failed since start
+func (s *Stats) GetActivity(endpointName string) EndpointActivity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if activity, ok := s.activity[endpointName]; ok {
+		return *activity
+	}
+	return EndpointActivity{}
+}
+
+// RecordHedged records a request that was hedged to a second endpoint
+func (s *Stats) RecordHedged() {
+	atomic.AddInt64(&s.hedged, 1)
+}
+
+// GetHedgedRequests returns the number of hedged requests since start
+func (s *Stats) GetHedgedRequests() int64 {
+	return atomic.LoadInt64(&s.hedged)
+}
+
+// GetStorageErrors returns the number of failed writes of stats to storage since start
+func (s *Stats) GetStorageErrors() int64 {
+	return atomic.LoadInt64(&s.storageErrors)
+}
+
+// RecordKeyUsage adds delta to the usage of one API key of an endpoint
+func (s *Stats) RecordKeyUsage(endpointName, key string, delta KeyUsage) {
+	hash := hashAPIKey(key)
+	s.mu.Lock()
+	// Like client usage, the delta is stored only after the reload, so it is not counted twice
+	s.loadKeyUsage()
+	usage, ok := s.keyUsage[endpointName+"\x00"+hash]
+	if !ok {
+		usage = &KeyUsage{}
+		s.keyUsage[endpointName+"\x00"+hash] = usage
+	}
+	usage.Requests += delta.Requests
+	usage.Errors += delta.Errors
+	usage.InputTokens += delta.InputTokens
+	usage.OutputTokens += delta.OutputTokens
+	s.mu.Unlock()
+
+	stat := &KeyStatRecord{
+		EndpointName: endpointName,
+		KeyHash:      hash,
+		Date:         time.Now().Format("2006-01-02"),
+		Requests:     delta.Requests,
+		Errors:       delta.Errors,
+		InputTokens:  delta.InputTokens,
+		OutputTokens: delta.OutputTokens,
+		DeviceID:     s.deviceID,
+	}
+	if err := s.storage.RecordKeyStat(stat); err != nil {
+		atomic.AddInt64(&s.storageErrors, 1)
+		logger.Error("Failed to record key usage: %v", err)
+	}
+}
+
+// GetKeyUsage returns the total usage of one API key of an endpoint
+func (s *Stats) GetKeyUsage(endpointName, key string) KeyUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loadKeyUsage()
+	if usage, ok := s.keyUsage[endpointName+"\x00"+hashAPIKey(key)]; ok {
+		return *usage
+	}
+	return KeyUsage{}
+}
+
+// loadKeyUsage loads the API key usage from storage once. On error the counters at hand are
+// kept and the next call tries again. Caller must hold mu.
+func (s *Stats) loadKeyUsage() {
+	if s.keysLoaded {
+		return
+	}
+
+	records, err := s.storage.GetKeyStats()
+	if err != nil {
+		logger.Error("Failed to load key usage: %v", err)
+		return
+	}
+
+	s.keysLoaded = true
+	s.keyUsage = make(map[string]*KeyUsage)
+	for _, record := range records {
+		v := reflect.ValueOf(record)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		s.keyUsage[v.FieldByName("EndpointName").String()+"\x00"+v.FieldByName("KeyHash").String()] = &KeyUsage{
+			Requests:     int(v.FieldByName("Requests").Int()),
+			Errors:       int(v.FieldByName("Errors").Int()),
+			InputTokens:  int(v.FieldByName("InputTokens").Int()),
+			OutputTokens: int(v.FieldByName("OutputTokens").Int()),
+		}
+	}
+}
+
+// hashAPIKey returns the form an API key is kept in for usage stats, so the key itself is
+// never written to storage
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordClient records the usage of a client (access token name)
+func (s *Stats) RecordClient(clientName string, delta ClientUsage) {
+	now := time.Now()
+	s.mu.Lock()
+	s.addClientUsage(clientName, delta, now)
+	s.mu.Unlock()
+	s.storeClientUsage(clientName, delta, now)
+}
+
+// ReserveClient counts a new request of a client unless allow rejects the client's usage
+// so far. The check and the count happen under one lock, so concurrent requests cannot all
+// pass a quota that only has room for some of them. Nothing is counted and an error is
+// returned when the usage so far cannot be loaded from storage.
+func (s *Stats) ReserveClient(clientName string, allow func(daily, monthly ClientUsage) bool) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	if err := s.loadClientUsage(now); err != nil {
+		s.mu.Unlock()
+		return false, err
+	}
+	if !allow(s.clientUsage(clientName)) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	delta := ClientUsage{Requests: 1}
+	s.addClientUsage(clientName, delta, now)
+	s.mu.Unlock()
+	s.storeClientUsage(clientName, delta, now)
+	return true, nil
+}
+
+// addClientUsage adds delta to the client usage counters. The counters are (re)loaded from
+// storage before the delta is added, and the delta is stored only afterwards, so a reload
+// never counts it twice. If the reload fails, the delta goes to the counters at hand, which
+// the next successful reload replaces. Caller must hold mu.
+func (s *Stats) addClientUsage(clientName string, delta ClientUsage, now time.Time) {
+	if s.loadClientUsage(now) != nil && s.clientDaily == nil {
+		s.clientDaily = make(map[string]*ClientUsage)
+		s.clientMonthly = make(map[string]*ClientUsage)
+	}
+	for _, usage := range []map[string]*ClientUsage{s.clientDaily, s.clientMonthly} {
+		if usage[clientName] == nil {
+			usage[clientName] = &ClientUsage{}
+		}
+		usage[clientName].add(delta)
+	}
+}
+
+// storeClientUsage writes a client usage delta to storage
+func (s *Stats) storeClientUsage(clientName string, delta ClientUsage, now time.Time) {
+	stat := &ClientStatRecord{
+		ClientName:   clientName,
+		Date:         now.Format("2006-01-02"),
+		Requests:     delta.Requests,
+		Errors:       delta.Errors,
+		InputTokens:  delta.InputTokens,
+		OutputTokens: delta.OutputTokens,
+		DeviceID:     s.deviceID,
+	}
+	if err := s.storage.RecordClientStat(stat); err != nil {
+		atomic.AddInt64(&s.storageErrors, 1)
+		logger.Error("Failed to record client usage: %v", err)
+	}
+}
+
+// GetClientUsage returns the usage of a client today and in the current month
+func (s *Stats) GetClientUsage(clientName string) (ClientUsage, ClientUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadClientUsage(time.Now())
+	return s.clientUsage(clientName)
+}
+
+// clientUsage returns the loaded usage of a client today and in the current month. Caller must hold mu.
+func (s *Stats) clientUsage(clientName string) (ClientUsage, ClientUsage) {
+	var daily, monthly ClientUsage
+	if u := s.clientDaily[clientName]; u != nil {
+		daily = *u
+	}
+	if u := s.clientMonthly[clientName]; u != nil {
+		monthly = *u
+	}
+	return daily, monthly
+}
+
+// loadClientUsage (re)loads the client usage counters from storage when they are not
+// loaded yet or belong to a past day. On error the counters are left as they are and not
+// marked as loaded, so the next call tries again. Caller must hold mu.
+func (s *Stats) loadClientUsage(now time.Time) error {
+	today := now.Format("2006-01-02")
+	if s.clientDay == today {
+		return nil
+	}
+
+	month := now.Format("2006-01")
+	records, err := s.storage.GetClientStats(month+"-01", today)
+	if err != nil {
+		logger.Error("Failed to load client usage: %v", err)
+		return fmt.Errorf("failed to load client usage: %w", err)
+	}
+
+	s.clientDay = today
+	s.clientDaily = make(map[string]*ClientUsage)
+	s.clientMonthly = make(map[string]*ClientUsage)
+	for _, record := range records {
+		v := reflect.ValueOf(record)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		name := v.FieldByName("ClientName").String()
+		usage := ClientUsage{
+			Requests:     int(v.FieldByName("Requests").Int()),
+			Errors:       int(v.FieldByName("Errors").Int()),
+			InputTokens:  int(v.FieldByName("InputTokens").Int()),
+			OutputTokens: int(v.FieldByName("OutputTokens").Int()),
+		}
+		if s.clientMonthly[name] == nil {
+			s.clientMonthly[name] = &ClientUsage{}
+		}
+		s.clientMonthly[name].add(usage)
+		if v.FieldByName("Date").String() == today {
+			if s.clientDaily[name] == nil {
+				s.clientDaily[name] = &ClientUsage{}
+			}
+			s.clientDaily[name].add(usage)
+		}
+	}
+	return nil
+}
+
 // scheduleSave schedules a save operation with debounce to avoid frequent writes
 func (s *Stats) scheduleSave() {
 	s.saveMu.Lock()
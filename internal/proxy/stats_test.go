@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStatsStorage keeps client and API key usage records in memory
+type fakeStatsStorage struct {
+	mu           sync.Mutex
+	clients      []*ClientStatRecord
+	keys         []*KeyStatRecord
+	failLoads    int // GetClientStats calls that fail before it works again
+	failKeyLoads int // GetKeyStats calls that fail before it works again
+}
+
+func (f *fakeStatsStorage) RecordDailyStat(stat interface{}) error { return nil }
+func (f *fakeStatsStorage) GetTotalStats() (int, map[string]interface{}, error) {
+	return 0, nil, nil
+}
+func (f *fakeStatsStorage) GetDailyStats(endpointName, startDate, endDate string) ([]interface{}, error) {
+	return nil, nil
+}
+func (f *fakeStatsStorage) RecordRequestLogs(records interface{}) error      { return nil }
+func (f *fakeStatsStorage) PruneRequestLogs(before time.Time) (int64, error) { return 0, nil }
+func (f *fakeStatsStorage) Ping() error                                      { return nil }
+
+func (f *fakeStatsStorage) RecordClientStat(stat interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := *stat.(*ClientStatRecord)
+	f.clients = append(f.clients, &record)
+	return nil
+}
+
+func (f *fakeStatsStorage) GetClientStats(startDate, endDate string) ([]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failLoads > 0 {
+		f.failLoads--
+		return nil, errors.New("database is locked")
+	}
+	var records []interface{}
+	for _, record := range f.clients {
+		if record.Date >= startDate && record.Date <= endDate {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (f *fakeStatsStorage) RecordKeyStat(stat interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := *stat.(*KeyStatRecord)
+	f.keys = append(f.keys, &record)
+	return nil
+}
+
+func (f *fakeStatsStorage) GetKeyStats() ([]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failKeyLoads > 0 {
+		f.failKeyLoads--
+		return nil, errors.New("database is locked")
+	}
+	totals := make(map[string]*KeyStatRecord)
+	var records []interface{}
+	for _, record := range f.keys {
+		total, ok := totals[record.EndpointName+"\x00"+record.KeyHash]
+		if !ok {
+			total = &KeyStatRecord{EndpointName: record.EndpointName, KeyHash: record.KeyHash}
+			totals[record.EndpointName+"\x00"+record.KeyHash] = total
+			records = append(records, total)
+		}
+		total.Requests += record.Requests
+		total.Errors += record.Errors
+		total.InputTokens += record.InputTokens
+		total.OutputTokens += record.OutputTokens
+	}
+	return records, nil
+}
+
+func TestRecordClientCountsOnce(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name       string
+		stored     []*ClientStatRecord // usage stored before this Stats starts
+		loadedDay  string              // day the in-memory counters belong to, "" for not loaded
+		deltas     []ClientUsage
+		wantDaily  ClientUsage
+		wantStored int // records stored for today in the end
+	}{
+		{
+			name:       "first call after start",
+			stored:     []*ClientStatRecord{{ClientName: "ci", Date: today, Requests: 5, InputTokens: 100}},
+			deltas:     []ClientUsage{{Requests: 1}},
+			wantDaily:  ClientUsage{Requests: 6, InputTokens: 100},
+			wantStored: 2,
+		},
+		{
+			name:       "first call of a new day",
+			loadedDay:  "2000-01-01",
+			deltas:     []ClientUsage{{Requests: 1, InputTokens: 10}, {OutputTokens: 20}},
+			wantDaily:  ClientUsage{Requests: 1, InputTokens: 10, OutputTokens: 20},
+			wantStored: 2,
+		},
+		{
+			name:       "nothing stored yet",
+			deltas:     []ClientUsage{{Requests: 1}, {Errors: 1}, {Requests: 1}},
+			wantDaily:  ClientUsage{Requests: 2, Errors: 1},
+			wantStored: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStatsStorage{clients: tt.stored}
+			s := NewStats(storage, "test")
+			if tt.loadedDay != "" {
+				s.clientDay = tt.loadedDay
+				s.clientDaily = map[string]*ClientUsage{"ci": {Requests: 99}}
+				s.clientMonthly = map[string]*ClientUsage{"ci": {Requests: 99}}
+			}
+
+			for _, delta := range tt.deltas {
+				s.RecordClient("ci", delta)
+			}
+
+			daily, _ := s.GetClientUsage("ci")
+			if daily != tt.wantDaily {
+				t.Errorf("daily usage = %+v, want %+v", daily, tt.wantDaily)
+			}
+			records, _ := storage.GetClientStats(today, today)
+			if len(records) != tt.wantStored {
+				t.Errorf("stored %d records, want %d", len(records), tt.wantStored)
+			}
+
+			// A restart reloads what was stored and must arrive at the same numbers
+			restarted, _ := NewStats(storage, "test").GetClientUsage("ci")
+			if restarted != tt.wantDaily {
+				t.Errorf("daily usage after restart = %+v, want %+v", restarted, tt.wantDaily)
+			}
+		})
+	}
+}
+
+func TestLoadClientUsageSplitsDayAndMonth(t *testing.T) {
+	storage := &fakeStatsStorage{clients: []*ClientStatRecord{
+		{ClientName: "ci", Date: "2026-02-28", Requests: 7},
+		{ClientName: "ci", Date: "2026-03-01", Requests: 1},
+		{ClientName: "ci", Date: "2026-03-02", Requests: 2},
+		{ClientName: "ci", Date: "2026-03-02", Requests: 3},
+		{ClientName: "other", Date: "2026-03-02", Requests: 4},
+	}}
+
+	tests := []struct {
+		now         time.Time
+		wantDaily   int
+		wantMonthly int
+	}{
+		{now: time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local), wantDaily: 5, wantMonthly: 6},
+		{now: time.Date(2026, 3, 3, 0, 0, 0, 0, time.Local), wantDaily: 0, wantMonthly: 6},
+		{now: time.Date(2026, 2, 28, 23, 59, 0, 0, time.Local), wantDaily: 7, wantMonthly: 7},
+		{now: time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local), wantDaily: 0, wantMonthly: 0},
+	}
+
+	s := NewStats(storage, "test")
+	for _, tt := range tests {
+		s.mu.Lock()
+		s.loadClientUsage(tt.now)
+		daily, monthly := s.clientUsage("ci")
+		s.mu.Unlock()
+
+		if daily.Requests != tt.wantDaily || monthly.Requests != tt.wantMonthly {
+			t.Errorf("%s: requests today %d this month %d, want %d and %d",
+				tt.now.Format("2006-01-02"), daily.Requests, monthly.Requests, tt.wantDaily, tt.wantMonthly)
+		}
+	}
+}
+
+func TestReserveClientDoesNotOvershoot(t *testing.T) {
+	const limit = 10
+	s := NewStats(&fakeStatsStorage{}, "test")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _ := s.ReserveClient("ci", func(daily, monthly ClientUsage) bool {
+				return daily.Requests < limit
+			})
+			if ok {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != limit {
+		t.Errorf("admitted %d requests, want %d", admitted, limit)
+	}
+	if daily, _ := s.GetClientUsage("ci"); daily.Requests != limit {
+		t.Errorf("counted %d requests, want %d", daily.Requests, limit)
+	}
+}
+
+func TestReserveClientLoadFailure(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	underLimit := func(daily, monthly ClientUsage) bool { return daily.Requests < 5 }
+
+	tests := []struct {
+		name      string
+		stored    []*ClientStatRecord
+		loadedDay string // day the in-memory counters belong to, "" for not loaded
+		failLoads int
+		wantOK    []bool // outcome of consecutive reservations
+		wantErr   []bool
+		wantDaily int // requests counted today in the end
+	}{
+		{name: "storage works", stored: []*ClientStatRecord{{ClientName: "ci", Date: today, Requests: 4}},
+			wantOK: []bool{true, false}, wantErr: []bool{false, false}, wantDaily: 5},
+		{name: "first load fails, next one retries", stored: []*ClientStatRecord{{ClientName: "ci", Date: today, Requests: 4}},
+			failLoads: 1, wantOK: []bool{false, true, false}, wantErr: []bool{true, false, false}, wantDaily: 5},
+		{name: "failed reload on a new day keeps the quota closed", stored: []*ClientStatRecord{{ClientName: "ci", Date: today, Requests: 5}},
+			loadedDay: "2000-01-01", failLoads: 2, wantOK: []bool{false, false, false}, wantErr: []bool{true, true, false}, wantDaily: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStatsStorage{clients: tt.stored}
+			s := NewStats(storage, "test")
+			if tt.loadedDay != "" {
+				s.clientDay = tt.loadedDay
+				s.clientDaily = map[string]*ClientUsage{"ci": {Requests: 1}}
+				s.clientMonthly = map[string]*ClientUsage{"ci": {Requests: 1}}
+			}
+			storage.failLoads = tt.failLoads
+
+			for i := range tt.wantOK {
+				ok, err := s.ReserveClient("ci", underLimit)
+				if ok != tt.wantOK[i] || (err != nil) != tt.wantErr[i] {
+					t.Errorf("reservation %d: ok %v, err %v; want ok %v, error %v", i, ok, err, tt.wantOK[i], tt.wantErr[i])
+				}
+			}
+			if daily, _ := s.GetClientUsage("ci"); daily.Requests != tt.wantDaily {
+				t.Errorf("counted %d requests today, want %d", daily.Requests, tt.wantDaily)
+			}
+		})
+	}
+}
+
+func TestKeyUsagePersists(t *testing.T) {
+	const key = "sk-secret-key"
+
+	tests := []struct {
+		name         string
+		before       []KeyUsage // recorded before the restart
+		failKeyLoads int        // failed loads after the restart
+		after        []KeyUsage // recorded after the restart
+		want         KeyUsage
+	}{
+		{name: "usage survives a restart", before: []KeyUsage{{Requests: 1}, {InputTokens: 10, OutputTokens: 5}},
+			want: KeyUsage{Requests: 1, InputTokens: 10, OutputTokens: 5}},
+		{name: "usage after the restart adds up", before: []KeyUsage{{Requests: 2}}, after: []KeyUsage{{Requests: 1}, {Errors: 1}},
+			want: KeyUsage{Requests: 3, Errors: 1}},
+		{name: "failed load is retried without counting twice", before: []KeyUsage{{Requests: 2}}, failKeyLoads: 1,
+			after: []KeyUsage{{Requests: 1}}, want: KeyUsage{Requests: 3}},
+		{name: "nothing recorded", want: KeyUsage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &fakeStatsStorage{}
+			s := NewStats(storage, "test")
+			for _, delta := range tt.before {
+				s.RecordKeyUsage("ep", key, delta)
+			}
+
+			storage.failKeyLoads = tt.failKeyLoads
+			s = NewStats(storage, "test")
+			for _, delta := range tt.after {
+				s.RecordKeyUsage("ep", key, delta)
+			}
+
+			if got := s.GetKeyUsage("ep", key); got != tt.want {
+				t.Errorf("usage = %+v, want %+v", got, tt.want)
+			}
+			if got := s.GetKeyUsage("other", key); got != (KeyUsage{}) {
+				t.Errorf("usage of another endpoint = %+v, want none", got)
+			}
+			for _, record := range storage.keys {
+				if strings.Contains(record.KeyHash, key) {
+					t.Errorf("key stored as %q", record.KeyHash)
+				}
+			}
+		})
+	}
+}
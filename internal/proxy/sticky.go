@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// sessionHeaders are request headers clients use to identify a conversation
+var sessionHeaders = []string{"X-Claude-Code-Session-Id", "Session_id"}
+
+// affinityEntry remembers which endpoint served a conversation
+type affinityEntry struct {
+	key      string
+	endpoint string
+	expires  time.Time
+}
+
+// affinityCache maps conversation keys to endpoints, dropping entries after their TTL
+// or, once full, the least recently used ones
+type affinityCache struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // most recently used at the front
+}
+
+// newAffinityCache creates an empty affinity cache
+func newAffinityCache() *affinityCache {
+	return &affinityCache{
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns the endpoint a conversation is bound to
+func (c *affinityCache) get(key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*affinityEntry)
+	if now.After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.endpoint, true
+}
+
+// set binds a conversation to an endpoint and restarts its TTL
+func (c *affinityCache) set(key, endpoint string, ttl time.Duration, maxEntries int, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*affinityEntry)
+		entry.endpoint = endpoint
+		entry.expires = now.Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&affinityEntry{key: key, endpoint: endpoint, expires: now.Add(ttl)})
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*affinityEntry).key)
+	}
+}
+
+// conversationKey derives a stable key for the conversation a request belongs to: the session
+// header or metadata.user_id Claude Code sends, otherwise a hash of the system prompt and the
+// first user message. Returns "" when the request carries nothing to identify it by.
+func conversationKey(r *http.Request, bodyBytes []byte) string {
+	for _, name := range sessionHeaders {
+		if v := r.Header.Get(name); v != "" {
+			return hashKey("session", v)
+		}
+	}
+
+	var req struct {
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+		System       json.RawMessage `json:"system"`
+		Instructions string          `json:"instructions"`
+		Messages     []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.Unmarshal(bodyBytes, &req); err != nil {
+		return ""
+	}
+	if req.Metadata.UserID != "" {
+		return hashKey("user", req.Metadata.UserID)
+	}
+
+	var system, first []byte
+	system = append(system, req.System...)
+	system = append(system, req.Instructions...)
+	for _, msg := range req.Messages {
+		// OpenAI chat clients put the system prompt into the messages
+		if msg.Role == "system" || msg.Role == "developer" {
+			system = append(system, msg.Content...)
+			continue
+		}
+		if msg.Role == "user" {
+			first = msg.Content
+			break
+		}
+	}
+	if first == nil && len(req.Input) > 0 {
+		// Responses API: a plain string or a list of input items
+		var items []json.RawMessage
+		if json.Unmarshal(req.Input, &items) == nil {
+			if len(items) > 0 {
+				first = items[0]
+			}
+		} else {
+			first = req.Input
+		}
+	}
+	if first == nil {
+		return ""
+	}
+	return hashKey("prompt", string(system)+"\x00"+string(first))
+}
+
+// hashKey hashes a conversation identifier together with its kind
+func hashKey(kind, value string) string {
+	sum := sha256.Sum256([]byte(kind + ":" + value))
+	return hex.EncodeToString(sum[:16])
+}
+
+// stickyEndpoint returns the endpoint a conversation is bound to if it is among the candidates,
+// i.e. still healthy and able to take the request right away
+func (p *Proxy) stickyEndpoint(key string, candidates []config.Endpoint) (config.Endpoint, bool) {
+	if key == "" || !p.config.GetSticky().Enabled {
+		return config.Endpoint{}, false
+	}
+	name, ok := p.affinity.get(key, time.Now())
+	if !ok {
+		return config.Endpoint{}, false
+	}
+	for _, ep := range candidates {
+		if ep.Name == name {
+			return ep, true
+		}
+	}
+	return config.Endpoint{}, false
+}
+
+// bindConversation keeps a conversation on the endpoint that just served it
+func (p *Proxy) bindConversation(key, endpointName string) {
+	sticky := p.config.GetSticky()
+	if key == "" || !sticky.Enabled {
+		return
+	}
+	p.affinity.set(key, endpointName, time.Duration(sticky.TTL)*time.Second, sticky.MaxEntries, time.Now())
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// Load balancing strategy names
+const (
+	StrategyFailover      = "failover"       // Stick to the current endpoint until it fails
+	StrategyRoundRobin    = "round_robin"    // Spread requests across endpoints in order
+	StrategyWeighted      = "weighted"       // Pick endpoints randomly by Endpoint.Weight
+	StrategyLeastInFlight = "least_inflight" // Pick the endpoint with the fewest active requests
+)
+
+// Strategy decides which endpoint a request attempt should use
+type Strategy interface {
+	// Name returns the strategy name
+	Name() string
+
+	// Select picks the next endpoint from candidates (enabled endpoints not yet given up on)
+	Select(candidates []config.Endpoint) (config.Endpoint, bool)
+
+	// OnFailure is called when a request gives up on an endpoint
+	OnFailure(endpoint config.Endpoint)
+}
+
+// IsValidStrategy checks if the name refers to a built-in strategy
+func IsValidStrategy(name string) bool {
+	switch name {
+	case StrategyFailover, StrategyRoundRobin, StrategyWeighted, StrategyLeastInFlight:
+		return true
+	}
+	return false
+}
+
+// newStrategy creates a strategy by name, falling back to failover for unknown names
+func newStrategy(name string, p *Proxy) Strategy {
+	switch name {
+	case StrategyRoundRobin:
+		return &roundRobinStrategy{}
+	case StrategyWeighted:
+		return &weightedStrategy{rnd: rand.New(rand.NewSource(rand.Int63()))}
+	case StrategyLeastInFlight:
+		return &leastInFlightStrategy{proxy: p}
+	case StrategyFailover, "":
+		return &failoverStrategy{proxy: p}
+	default:
+		logger.Warn("Unknown load balancing strategy '%s', using %s", name, StrategyFailover)
+		return &failoverStrategy{proxy: p}
+	}
+}
+
+// failoverStrategy keeps using the current endpoint and rotates only on failure
+type failoverStrategy struct {
+	proxy *Proxy
+}
+
+func (s *failoverStrategy) Name() string {
+	return StrategyFailover
+}
+
+func (s *failoverStrategy) Select(candidates []config.Endpoint) (config.Endpoint, bool) {
+	if len(candidates) == 0 {
+		return config.Endpoint{}, false
+	}
+
+	current := s.proxy.getCurrentEndpoint()
+	eligible := make(map[string]bool, len(candidates))
+	for _, ep := range candidates {
+		if ep.Name == current.Name {
+			return ep, true
+		}
+		eligible[ep.Name] = true
+	}
+
+	// Current endpoint is not eligible, take the next eligible one in configured order
+	enabled := s.proxy.getEnabledEndpoints()
+	start := 0
+	for i, ep := range enabled {
+		if ep.Name == current.Name {
+			start = i + 1
+			break
+		}
+	}
+	for i := 0; i < len(enabled); i++ {
+		ep := enabled[(start+i)%len(enabled)]
+		if eligible[ep.Name] {
+			return ep, true
+		}
+	}
+
+	return candidates[0], true
+}
+
+func (s *failoverStrategy) OnFailure(endpoint config.Endpoint) {
+	if s.proxy.isCurrentEndpoint(endpoint.Name) {
+		s.proxy.rotateEndpoint()
+	}
+}
+
+// roundRobinStrategy hands out endpoints in turn
+type roundRobinStrategy struct {
+	counter uint64
+}
+
+func (s *roundRobinStrategy) Name() string {
+	return StrategyRoundRobin
+}
+
+func (s *roundRobinStrategy) Select(candidates []config.Endpoint) (config.Endpoint, bool) {
+	if len(candidates) == 0 {
+		return config.Endpoint{}, false
+	}
+	n := atomic.AddUint64(&s.counter, 1) - 1
+	return candidates[n%uint64(len(candidates))], true
+}
+
+func (s *roundRobinStrategy) OnFailure(endpoint config.Endpoint) {}
+
+// weightedStrategy picks endpoints randomly, proportional to their weight
+type weightedStrategy struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (s *weightedStrategy) Name() string {
+	return StrategyWeighted
+}
+
+func (s *weightedStrategy) Select(candidates []config.Endpoint) (config.Endpoint, bool) {
+	if len(candidates) == 0 {
+		return config.Endpoint{}, false
+	}
+
+	total := 0
+	for _, ep := range candidates {
+		total += endpointWeight(ep)
+	}
+
+	s.mu.Lock()
+	pick := s.rnd.Intn(total)
+	s.mu.Unlock()
+
+	for _, ep := range candidates {
+		pick -= endpointWeight(ep)
+		if pick < 0 {
+			return ep, true
+		}
+	}
+	return candidates[len(candidates)-1], true
+}
+
+func (s *weightedStrategy) OnFailure(endpoint config.Endpoint) {}
+
+// endpointWeight returns the effective weight of an endpoint (at least 1)
+func endpointWeight(ep config.Endpoint) int {
+	if ep.Weight <= 0 {
+		return 1
+	}
+	return ep.Weight
+}
+
+// leastInFlightStrategy picks the endpoint with the fewest active requests
+type leastInFlightStrategy struct {
+	proxy *Proxy
+}
+
+func (s *leastInFlightStrategy) Name() string {
+	return StrategyLeastInFlight
+}
+
+func (s *leastInFlightStrategy) Select(candidates []config.Endpoint) (config.Endpoint, bool) {
+	if len(candidates) == 0 {
+		return config.Endpoint{}, false
+	}
+
+	// Ties go to the endpoint that comes first in configured order
+	best := candidates[0]
+	bestCount := s.proxy.inFlight(best.Name)
+	for _, ep := range candidates[1:] {
+		if count := s.proxy.inFlight(ep.Name); count < bestCount {
+			best = ep
+			bestCount = count
+		}
+	}
+	return best, true
+}
+
+func (s *leastInFlightStrategy) OnFailure(endpoint config.Endpoint) {}
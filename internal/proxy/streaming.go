@@ -5,10 +5,14 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/lich0821/ccNexus/internal/capture"
 	"github.com/lich0821/ccNexus/internal/config"
 	"github.com/lich0821/ccNexus/internal/logger"
 	"github.com/lich0821/ccNexus/internal/transformer"
@@ -17,24 +21,59 @@ import (
 	"github.com/lich0821/ccNexus/internal/transformer/cx/responses"
 )
 
-// handleStreamingResponse processes streaming SSE responses
-func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Response, endpoint config.Endpoint, trans transformer.Transformer, transformerName string, thinkingEnabled bool, modelName string, bodyBytes []byte) (int, int, string) {
-	// Copy response headers except Content-Length and Content-Encoding
-	for key, values := range resp.Header {
-		if key == "Content-Length" || key == "Content-Encoding" {
-			continue
-		}
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
-	}
-	w.WriteHeader(resp.StatusCode)
+// handleStreamingResponse processes streaming SSE responses.
+// Response headers are held until the first valid upstream event arrives; if the upstream
+// stalls, fails or ends before that, nothing is written and an error is returned so the
+// request can be retried on another endpoint. A captured exchange receives every raw upstream
+// line and every event sent to the client.
+func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Response, endpoint config.Endpoint, trans transformer.Transformer, transformerName string, thinkingEnabled bool, modelName string, bodyBytes []byte, started time.Time, ex *capture.Exchange) (int, int, string, error) {
+	defer resp.Body.Close()
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		logger.Error("[%s] ResponseWriter does not support flushing", endpoint.Name)
-		resp.Body.Close()
-		return 0, 0, ""
+		return 0, 0, "", nil
+	}
+
+	// Abort the upstream read if no event shows up in time
+	var timedOut atomic.Bool
+	var firstEventTimer *time.Timer
+	timeout := time.Duration(p.config.GetStreaming().FirstEventTimeout) * time.Second
+	if timeout > 0 {
+		firstEventTimer = time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			resp.Body.Close()
+		})
+		defer firstEventTimer.Stop()
+	}
+
+	committed := false
+	commit := func() bool {
+		if firstEventTimer != nil && !firstEventTimer.Stop() {
+			return false
+		}
+		// Copy response headers except Content-Length and Content-Encoding
+		for key, values := range resp.Header {
+			if key == "Content-Length" || key == "Content-Encoding" {
+				continue
+			}
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		ex.SetClientResponse(resp.StatusCode, w.Header())
+		committed = true
+		p.metrics.recordFirstToken(endpoint.Name, time.Since(started))
+		return true
+	}
+
+	// earlyFailure explains why the stream failed before anything was sent to the client
+	earlyFailure := func(reason error) error {
+		if timedOut.Load() {
+			return fmt.Errorf("no stream event within %s", timeout)
+		}
+		return reason
 	}
 
 	// Handle gzip-encoded response body
@@ -42,9 +81,7 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		gzipReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			logger.Error("[%s] Failed to create gzip reader: %v", endpoint.Name, err)
-			resp.Body.Close()
-			return 0, 0, ""
+			return 0, 0, "", earlyFailure(fmt.Errorf("failed to create gzip reader: %w", err))
 		}
 		defer gzipReader.Close()
 		reader = gzipReader
@@ -69,7 +106,10 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 1024*1024)
 
+	failover := p.getStrategy().Name() == StrategyFailover
+
 	var inputTokens, outputTokens int
+	var cache cacheUsage
 	var buffer bytes.Buffer
 	var outputText strings.Builder
 	eventCount := 0
@@ -77,14 +117,19 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 
 	for scanner.Scan() && !streamDone {
 		line := scanner.Text()
+		ex.AppendUpstreamBody([]byte(line + "\n"))
 
-		if !p.isCurrentEndpoint(endpoint.Name) {
+		// Only failover pins traffic to a current endpoint; other strategies share endpoints
+		if committed && failover && !p.isCurrentEndpoint(endpoint.Name) {
 			logger.Warn("[%s] Endpoint switched during streaming, terminating stream gracefully", endpoint.Name)
 			streamDone = true
 			break
 		}
 
 		if strings.Contains(line, "data: [DONE]") {
+			if !committed {
+				return 0, 0, "", earlyFailure(fmt.Errorf("stream ended before the first event"))
+			}
 			streamDone = true
 			buffer.WriteString(line + "\n")
 			eventData := buffer.Bytes()
@@ -94,6 +139,7 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 			if err == nil && len(transformedEvent) > 0 {
 				logger.DebugLog("[%s] SSE Event #%d (Transformed): %s", endpoint.Name, eventCount+1, string(transformedEvent))
 				w.Write(transformedEvent)
+				ex.AppendClientBody(transformedEvent)
 				flusher.Flush()
 			}
 			break
@@ -102,8 +148,27 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 		buffer.WriteString(line + "\n")
 
 		if line == "" {
-			eventCount++
 			eventData := buffer.Bytes()
+
+			if !committed {
+				// Keep-alive comments and stray blank lines do not prove the upstream is working
+				if !hasSSEData(eventData) {
+					buffer.Reset()
+					continue
+				}
+				if isSSEErrorEvent(eventData) {
+					errMsg := strings.TrimSpace(string(eventData))
+					if len(errMsg) > 200 {
+						errMsg = errMsg[:200] + "..."
+					}
+					return 0, 0, "", fmt.Errorf("upstream error before the first event: %s", errMsg)
+				}
+				if !commit() {
+					return 0, 0, "", earlyFailure(fmt.Errorf("stream aborted before the first event"))
+				}
+			}
+
+			eventCount++
 			logger.DebugLog("[%s] SSE Event #%d (Original): %s", endpoint.Name, eventCount, string(eventData))
 
 			transformedEvent, err := p.transformStreamEvent(eventData, trans, transformerName, streamCtx)
@@ -112,9 +177,10 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 			} else if len(transformedEvent) > 0 {
 				logger.DebugLog("[%s] SSE Event #%d (Transformed): %s", endpoint.Name, eventCount, string(transformedEvent))
 
-				p.extractTokensFromEvent(transformedEvent, &inputTokens, &outputTokens)
+				p.extractTokensFromEvent(transformedEvent, &inputTokens, &outputTokens, &cache)
 				p.extractTextFromEvent(transformedEvent, &outputText)
 
+				ex.AppendClientBody(transformedEvent)
 				if _, writeErr := w.Write(transformedEvent); writeErr != nil {
 					// Client disconnected (broken pipe) is normal for cancelled requests
 					if strings.Contains(writeErr.Error(), "broken pipe") || strings.Contains(writeErr.Error(), "connection reset") {
@@ -131,12 +197,53 @@ func (p *Proxy) handleStreamingResponse(w http.ResponseWriter, resp *http.Respon
 		}
 	}
 
+	if !committed {
+		if err := scanner.Err(); err != nil {
+			return 0, 0, "", earlyFailure(fmt.Errorf("stream failed before the first event: %w", err))
+		}
+		return 0, 0, "", earlyFailure(fmt.Errorf("stream ended before the first event"))
+	}
+
 	if err := scanner.Err(); err != nil {
 		logger.Error("[%s] Scanner error: %v", endpoint.Name, err)
 	}
+	p.metrics.recordCacheTokens(endpoint.Name, cache)
+
+	return inputTokens, outputTokens, outputText.String(), nil
+}
 
-	resp.Body.Close()
-	return inputTokens, outputTokens, outputText.String()
+// hasSSEData checks if an SSE event carries a data field
+func hasSSEData(eventData []byte) bool {
+	for _, line := range strings.Split(string(eventData), "\n") {
+		if strings.HasPrefix(line, "data:") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSSEErrorEvent checks if an SSE event reports an upstream error, either as an
+// "event: error" (Claude) or as a data payload with an error object (OpenAI, Gemini)
+func isSSEErrorEvent(eventData []byte) bool {
+	for _, line := range strings.Split(string(eventData), "\n") {
+		if strings.TrimSpace(line) == "event: error" {
+			return true
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var payload struct {
+			Type  string          `json:"type"`
+			Error json.RawMessage `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &payload); err != nil {
+			continue
+		}
+		if payload.Type == "error" || (len(payload.Error) > 0 && string(payload.Error) != "null") {
+			return true
+		}
+	}
+	return false
 }
 
 // transformStreamEvent transforms a single SSE event
@@ -175,7 +282,7 @@ func (p *Proxy) transformStreamEvent(eventData []byte, trans transformer.Transfo
 }
 
 // extractTokensFromEvent extracts token counts from SSE event
-func (p *Proxy) extractTokensFromEvent(eventData []byte, inputTokens, outputTokens *int) {
+func (p *Proxy) extractTokensFromEvent(eventData []byte, inputTokens, outputTokens *int, cache *cacheUsage) {
 	scanner := bufio.NewScanner(bytes.NewReader(eventData))
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -196,6 +303,7 @@ func (p *Proxy) extractTokensFromEvent(eventData []byte, inputTokens, outputToke
 					if input, ok := usage["input_tokens"].(float64); ok {
 						*inputTokens = int(input)
 					}
+					cache.add(usage)
 				}
 			}
 		} else if eventType == "message_delta" {
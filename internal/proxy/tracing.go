@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"net/http"
+)
+
+// finishSpan ends the span of an attempt with the upstream status, or with the error that
+// ended the attempt before a response arrived
+func (a *upstreamAttempt) finishSpan(status int, err error) {
+	if err != nil {
+		a.span.SetError(err.Error())
+	} else {
+		a.span.SetAttribute("http.response.status_code", status)
+		if status >= http.StatusBadRequest {
+			a.span.SetError(http.StatusText(status))
+		}
+	}
+	a.span.End()
+}
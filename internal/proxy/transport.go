@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+// transportKey identifies a pooled transport
+type transportKey struct {
+	endpoint string
+	proxyURL string // "" for direct connections
+}
+
+// pooledTransport is a cached transport with the settings it was built from
+type pooledTransport struct {
+	transport *http.Transport
+	settings  config.TransportConfig
+}
+
+// transportManager keeps one transport per (endpoint, proxy URL) so that TLS sessions
+// and keep-alive connections are reused across requests
+type transportManager struct {
+	mu         sync.Mutex
+	transports map[transportKey]*pooledTransport
+}
+
+// newTransportManager creates an empty transport manager
+func newTransportManager() *transportManager {
+	return &transportManager{transports: make(map[transportKey]*pooledTransport)}
+}
+
+// get returns the transport for an endpoint, building a new one when there is none yet
+// or the settings changed since it was built
+func (m *transportManager) get(endpointName, proxyURL string, settings config.TransportConfig) (*http.Transport, error) {
+	key := transportKey{endpoint: endpointName, proxyURL: proxyURL}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cached, ok := m.transports[key]; ok {
+		if cached.settings == settings {
+			return cached.transport, nil
+		}
+		// Requests still using the old transport finish normally, its idle connections go now
+		cached.transport.CloseIdleConnections()
+		delete(m.transports, key)
+	}
+
+	transport, err := newTransport(proxyURL, settings)
+	if err != nil {
+		return nil, err
+	}
+	m.transports[key] = &pooledTransport{transport: transport, settings: settings}
+	logger.Debug("[TRANSPORT] New connection pool for %s (proxy: %q)", endpointName, proxyURL)
+	return transport, nil
+}
+
+// prune drops the transports that no longer match the configuration: removed endpoints,
+// a changed proxy URL or changed connection pool settings
+func (m *transportManager) prune(cfg *config.Config) {
+	wanted := make(map[transportKey]config.TransportConfig)
+	for _, ep := range cfg.GetEndpoints() {
+		wanted[transportKey{endpoint: ep.Name, proxyURL: endpointProxyURL(cfg, ep)}] = endpointTransportSettings(cfg, ep)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, cached := range m.transports {
+		if settings, ok := wanted[key]; !ok || cached.settings != settings {
+			cached.transport.CloseIdleConnections()
+			delete(m.transports, key)
+		}
+	}
+}
+
+// endpointProxyURL returns the outbound proxy used for an endpoint ("" for direct):
+// its own proxy URL if set, otherwise the global one
+func endpointProxyURL(cfg *config.Config, endpoint config.Endpoint) string {
+	switch endpoint.ProxyURL {
+	case config.DirectProxy:
+		return ""
+	case "":
+		if proxyCfg := cfg.GetProxy(); proxyCfg != nil {
+			return proxyCfg.URL
+		}
+		return ""
+	default:
+		return endpoint.ProxyURL
+	}
+}
+
+// endpointTransportSettings returns the connection pool settings with an endpoint's
+// connect and first-byte timeouts applied
+func endpointTransportSettings(cfg *config.Config, endpoint config.Endpoint) config.TransportConfig {
+	settings := cfg.GetTransport()
+	if endpoint.ConnectTimeout > 0 {
+		settings.DialTimeout = endpoint.ConnectTimeout
+	}
+	if endpoint.FirstByteTimeout > 0 {
+		settings.ResponseHeaderTimeout = endpoint.FirstByteTimeout
+	}
+	return settings
+}
+
+// endpointTotalTimeout returns the overall request timeout of an endpoint, or fallback if it sets none
+func endpointTotalTimeout(endpoint config.Endpoint, fallback time.Duration) time.Duration {
+	if endpoint.TotalTimeout > 0 {
+		return time.Duration(endpoint.TotalTimeout) * time.Second
+	}
+	return fallback
+}
+
+// newTransport builds a transport with the given pool settings, dialing through proxyURL if set
+func newTransport(proxyURL string, settings config.TransportConfig) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(settings.DialTimeout) * time.Second,
+		KeepAlive: time.Duration(settings.KeepAlive) * time.Second,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !settings.DisableHTTP2,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(settings.IdleConnTimeout) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(settings.TLSHandshakeTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(settings.ResponseHeaderTimeout) * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if settings.DisableHTTP2 {
+		// A non-nil empty map keeps net/http from negotiating h2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if proxyURL == "" {
+		return transport, nil
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "socks5", "socks5h":
+		auth := &proxy.Auth{}
+		if parsed.User != nil {
+			auth.User = parsed.User.Username()
+			auth.Password, _ = parsed.User.Password()
+		} else {
+			auth = nil
+		}
+		socks, err := proxy.SOCKS5("tcp", parsed.Host, auth, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		}
+		if contextDialer, ok := socks.(proxy.ContextDialer); ok {
+			transport.DialContext = contextDialer.DialContext
+		} else {
+			transport.DialContext = nil
+			transport.Dial = socks.Dial
+		}
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(parsed)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsed.Scheme)
+	}
+
+	return transport, nil
+}
+
+// transportFor returns the pooled transport for an endpoint, falling back to a direct
+// connection when its proxy URL is unusable
+func (p *Proxy) transportFor(endpoint config.Endpoint) *http.Transport {
+	settings := endpointTransportSettings(p.config, endpoint)
+	proxyURL := endpointProxyURL(p.config, endpoint)
+
+	transport, err := p.transports.get(endpoint.Name, proxyURL, settings)
+	if err != nil {
+		logger.Warn("Failed to create proxy transport: %v, using direct connection", err)
+		transport, _ = p.transports.get(endpoint.Name, "", settings)
+	} else if proxyURL != "" {
+		logger.Debug("Using proxy: %s", proxyURL)
+	}
+	return transport
+}
+
+// NewEndpointClient creates a standalone HTTP client that honors an endpoint's proxy and
+// timeouts, for one-off requests such as endpoint tests. timeout applies unless the
+// endpoint sets a total timeout of its own.
+func NewEndpointClient(cfg *config.Config, endpoint config.Endpoint, timeout time.Duration) *http.Client {
+	settings := endpointTransportSettings(cfg, endpoint)
+	transport, err := newTransport(endpointProxyURL(cfg, endpoint), settings)
+	if err != nil {
+		logger.Warn("Failed to create proxy transport: %v, using direct connection", err)
+		transport, _ = newTransport("", settings)
+	}
+	// Not pooled, so do not leave idle connections behind
+	transport.DisableKeepAlives = true
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   endpointTotalTimeout(endpoint, timeout),
+	}
+}
@@ -15,15 +15,9 @@ import (
     "github.com/lich0821/ccNexus/internal/storage"
 )
 
-// createHTTPClient creates an HTTP client with optional proxy support
-func (e *EndpointService) createHTTPClient(timeout time.Duration) *http.Client {
-    client := &http.Client{Timeout: timeout}
-    if proxyCfg := e.config.GetProxy(); proxyCfg != nil && proxyCfg.URL != "" {
-        if transport, err := proxy.CreateProxyTransport(proxyCfg.URL); err == nil {
-            client.Transport = transport
-        }
-    }
-    return client
+// createHTTPClient creates an HTTP client using the endpoint's proxy and timeouts
+func (e *EndpointService) createHTTPClient(endpoint config.Endpoint, timeout time.Duration) *http.Client {
+    return proxy.NewEndpointClient(e.config, endpoint, timeout)
 }
 
 // Test endpoint constants
@@ -155,23 +149,21 @@ func (e *EndpointService) UpdateEndpoint(index int, name, apiUrl, apiKey, transf
         }
     }
 
-    enabled := endpoints[index].Enabled
-
     if transformer == "" {
         transformer = "claude"
     }
 
     apiUrl = normalizeAPIUrl(apiUrl)
 
-    endpoints[index] = config.Endpoint{
-        Name:        name,
-        APIUrl:      apiUrl,
-        APIKey:      apiKey,
-        Enabled:     enabled,
-        Transformer: transformer,
-        Model:       model,
-        Remark:      remark,
-    }
+    // Keep enabled state and settings that are not editable here (e.g. weight)
+    endpoint := endpoints[index]
+    endpoint.Name = name
+    endpoint.APIUrl = apiUrl
+    endpoint.APIKey = apiKey
+    endpoint.Transformer = transformer
+    endpoint.Model = model
+    endpoint.Remark = remark
+    endpoints[index] = endpoint
 
     e.config.UpdateEndpoints(endpoints)
 
@@ -439,7 +431,7 @@ func (e *EndpointService) TestEndpoint(index int) string {
         req.URL.RawQuery = q.Encode()
     }
 
-    client := e.createHTTPClient(30 * time.Second)
+    client := e.createHTTPClient(endpoint, 30 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         result := map[string]interface{}{
@@ -558,7 +550,7 @@ func (e *EndpointService) TestEndpointLight(index int) string {
     }
 
     // Step 1: Try models API
-    statusCode, err := e.testModelsAPI(normalizedURL, endpoint.APIKey, transformer)
+    statusCode, err := e.testModelsAPI(endpoint, normalizedURL, transformer)
     if err == nil {
         return e.testResult(true, "ok", "models", "Models API accessible")
     }
@@ -568,7 +560,7 @@ func (e *EndpointService) TestEndpointLight(index int) string {
 
     // Step 2: Try token count (Claude) or billing API (OpenAI)
     if transformer == "claude" {
-        statusCode, err = e.testTokenCountAPI(normalizedURL, endpoint.APIKey)
+        statusCode, err = e.testTokenCountAPI(endpoint, normalizedURL)
         if err == nil {
             return e.testResult(true, "ok", "token_count", "Token count API accessible")
         }
@@ -576,7 +568,7 @@ func (e *EndpointService) TestEndpointLight(index int) string {
             return e.testResult(false, "invalid_key", "token_count", fmt.Sprintf("Authentication failed: HTTP %d", statusCode))
         }
     } else if transformer == "openai" || transformer == "openai2" {
-        statusCode, err = e.testBillingAPI(normalizedURL, endpoint.APIKey)
+        statusCode, err = e.testBillingAPI(endpoint, normalizedURL)
         if err == nil {
             return e.testResult(true, "ok", "billing", "Billing API accessible")
         }
@@ -586,7 +578,7 @@ func (e *EndpointService) TestEndpointLight(index int) string {
     }
 
     // Step 3: Minimal request (fallback)
-    statusCode, err = e.testMinimalRequest(normalizedURL, endpoint.APIKey, transformer, endpoint.Model)
+    statusCode, err = e.testMinimalRequest(endpoint, normalizedURL, transformer)
     if err == nil {
         return e.testResult(true, "ok", "minimal", "Minimal request successful")
     }
@@ -629,21 +621,21 @@ func (e *EndpointService) TestAllEndpointsZeroCost() string {
 
         status := "unknown"
 
-        statusCode, err := e.testModelsAPI(normalizedURL, endpoint.APIKey, transformer)
+        statusCode, err := e.testModelsAPI(endpoint, normalizedURL, transformer)
         if err == nil {
             status = "ok"
         } else if statusCode == 401 || statusCode == 403 {
             status = "invalid_key"
         } else {
             if transformer == "claude" {
-                statusCode, err = e.testTokenCountAPI(normalizedURL, endpoint.APIKey)
+                statusCode, err = e.testTokenCountAPI(endpoint, normalizedURL)
                 if err == nil {
                     status = "ok"
                 } else if statusCode == 401 || statusCode == 403 {
                     status = "invalid_key"
                 }
             } else if transformer == "openai" || transformer == "openai2" {
-                statusCode, err = e.testBillingAPI(normalizedURL, endpoint.APIKey)
+                statusCode, err = e.testBillingAPI(endpoint, normalizedURL)
                 if err == nil {
                     status = "ok"
                 } else if statusCode == 401 || statusCode == 403 {
@@ -659,10 +651,10 @@ func (e *EndpointService) TestAllEndpointsZeroCost() string {
     return string(data)
 }
 
-func (e *EndpointService) testModelsAPI(apiUrl, apiKey, transformer string) (int, error) {
+func (e *EndpointService) testModelsAPI(endpoint config.Endpoint, apiUrl, transformer string) (int, error) {
     var url string
     if transformer == "gemini" {
-        url = fmt.Sprintf("%s/v1beta/models?key=%s", apiUrl, apiKey)
+        url = fmt.Sprintf("%s/v1beta/models?key=%s", apiUrl, endpoint.APIKey)
     } else {
         url = fmt.Sprintf("%s/v1/models", apiUrl)
     }
@@ -673,10 +665,10 @@ func (e *EndpointService) testModelsAPI(apiUrl, apiKey, transformer string) (int
     }
 
     if transformer != "gemini" {
-        req.Header.Set("Authorization", "Bearer "+apiKey)
+        req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
     }
 
-    client := e.createHTTPClient(15 * time.Second)
+    client := e.createHTTPClient(endpoint, 15 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return 0, err
@@ -714,7 +706,7 @@ func (e *EndpointService) testModelsAPI(apiUrl, apiKey, transformer string) (int
     return resp.StatusCode, fmt.Errorf("unexpected response format")
 }
 
-func (e *EndpointService) testTokenCountAPI(apiUrl, apiKey string) (int, error) {
+func (e *EndpointService) testTokenCountAPI(endpoint config.Endpoint, apiUrl string) (int, error) {
     url := fmt.Sprintf("%s/v1/messages/count_tokens", apiUrl)
 
     body, _ := json.Marshal(map[string]interface{}{
@@ -730,11 +722,11 @@ func (e *EndpointService) testTokenCountAPI(apiUrl, apiKey string) (int, error)
     }
 
     req.Header.Set("Content-Type", "application/json")
-    req.Header.Set("x-api-key", apiKey)
+    req.Header.Set("x-api-key", endpoint.APIKey)
     req.Header.Set("anthropic-version", "2023-06-01")
     req.Header.Set("anthropic-beta", "token-counting-2024-11-01")
 
-    client := e.createHTTPClient(15 * time.Second)
+    client := e.createHTTPClient(endpoint, 15 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return 0, err
@@ -762,7 +754,7 @@ func (e *EndpointService) testTokenCountAPI(apiUrl, apiKey string) (int, error)
     return resp.StatusCode, nil
 }
 
-func (e *EndpointService) testBillingAPI(apiUrl, apiKey string) (int, error) {
+func (e *EndpointService) testBillingAPI(endpoint config.Endpoint, apiUrl string) (int, error) {
     url := fmt.Sprintf("%s/v1/dashboard/billing/credit_grants", apiUrl)
 
     req, err := http.NewRequest("GET", url, nil)
@@ -770,9 +762,9 @@ func (e *EndpointService) testBillingAPI(apiUrl, apiKey string) (int, error) {
         return 0, err
     }
 
-    req.Header.Set("Authorization", "Bearer "+apiKey)
+    req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
 
-    client := e.createHTTPClient(15 * time.Second)
+    client := e.createHTTPClient(endpoint, 15 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return 0, err
@@ -796,7 +788,8 @@ func (e *EndpointService) testBillingAPI(apiUrl, apiKey string) (int, error) {
     return resp.StatusCode, nil
 }
 
-func (e *EndpointService) testMinimalRequest(apiUrl, apiKey, transformer, model string) (int, error) {
+func (e *EndpointService) testMinimalRequest(endpoint config.Endpoint, apiUrl, transformer string) (int, error) {
+    model := endpoint.Model
     var url string
     var body []byte
 
@@ -836,7 +829,7 @@ func (e *EndpointService) testMinimalRequest(apiUrl, apiKey, transformer, model
         if model == "" {
             model = "gemini-2.0-flash"
         }
-        url = fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", apiUrl, model, apiKey)
+        url = fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", apiUrl, model, endpoint.APIKey)
         body, _ = json.Marshal(map[string]interface{}{
             "contents":         []map[string]interface{}{{"parts": []map[string]string{{"text": "Hi"}}}},
             "generationConfig": map[string]int{"maxOutputTokens": 1},
@@ -852,13 +845,13 @@ func (e *EndpointService) testMinimalRequest(apiUrl, apiKey, transformer, model
 
     req.Header.Set("Content-Type", "application/json")
     if transformer == "claude" {
-        req.Header.Set("x-api-key", apiKey)
+        req.Header.Set("x-api-key", endpoint.APIKey)
         req.Header.Set("anthropic-version", "2023-06-01")
     } else if transformer != "gemini" {
-        req.Header.Set("Authorization", "Bearer "+apiKey)
+        req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
     }
 
-    client := e.createHTTPClient(30 * time.Second)
+    client := e.createHTTPClient(endpoint, 30 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return 0, err
@@ -871,8 +864,9 @@ func (e *EndpointService) testMinimalRequest(apiUrl, apiKey, transformer, model
     return resp.StatusCode, nil
 }
 
-// FetchModels fetches available models from the API provider
-func (e *EndpointService) FetchModels(apiUrl, apiKey, transformer string) string {
+// FetchModelsWithProxy fetches available models from the API provider with the proxy and
+// timeouts of settings (an empty ProxyURL uses the global proxy, "direct" bypasses it)
+func (e *EndpointService) FetchModelsWithProxy(apiUrl, apiKey, transformer string, settings config.Endpoint) string {
     logger.Info("Fetching models for transformer: %s", transformer)
 
     if transformer == "" {
@@ -884,16 +878,26 @@ func (e *EndpointService) FetchModels(apiUrl, apiKey, transformer string) string
         normalizedAPIUrl = "https://" + normalizedAPIUrl
     }
 
+    endpoint := config.Endpoint{
+        APIUrl:           normalizedAPIUrl,
+        APIKey:           apiKey,
+        Transformer:      transformer,
+        ProxyURL:         settings.ProxyURL,
+        ConnectTimeout:   settings.ConnectTimeout,
+        FirstByteTimeout: settings.FirstByteTimeout,
+        TotalTimeout:     settings.TotalTimeout,
+    }
+
     var models []string
     var err error
 
     switch transformer {
     case "claude":
-        models, err = e.fetchOpenAIModels(normalizedAPIUrl, apiKey)
+        models, err = e.fetchOpenAIModels(endpoint, normalizedAPIUrl)
     case "openai", "openai2":
-        models, err = e.fetchOpenAIModels(normalizedAPIUrl, apiKey)
+        models, err = e.fetchOpenAIModels(endpoint, normalizedAPIUrl)
     case "gemini":
-        models, err = e.fetchGeminiModels(normalizedAPIUrl, apiKey)
+        models, err = e.fetchGeminiModels(endpoint, normalizedAPIUrl)
     default:
         result := map[string]interface{}{
             "success": false,
@@ -914,6 +918,11 @@ func (e *EndpointService) FetchModels(apiUrl, apiKey, transformer string) string
         return string(data)
     }
 
+    // Clients discover the fetched models through the proxy's /v1/models
+    if e.proxy != nil {
+        e.proxy.CacheModels(normalizedAPIUrl, models)
+    }
+
     result := map[string]interface{}{
         "success": true,
         "message": fmt.Sprintf("Found %d models", len(models)),
@@ -924,7 +933,7 @@ func (e *EndpointService) FetchModels(apiUrl, apiKey, transformer string) string
     return string(data)
 }
 
-func (e *EndpointService) fetchOpenAIModels(apiUrl, apiKey string) ([]string, error) {
+func (e *EndpointService) fetchOpenAIModels(endpoint config.Endpoint, apiUrl string) ([]string, error) {
     url := fmt.Sprintf("%s/v1/models", apiUrl)
 
     req, err := http.NewRequest("GET", url, nil)
@@ -932,9 +941,9 @@ func (e *EndpointService) fetchOpenAIModels(apiUrl, apiKey string) ([]string, er
         return nil, fmt.Errorf("failed to create request: %v", err)
     }
 
-    req.Header.Set("Authorization", "Bearer "+apiKey)
+    req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
 
-    client := e.createHTTPClient(30 * time.Second)
+    client := e.createHTTPClient(endpoint, 30 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return nil, fmt.Errorf("request failed: %v", err)
@@ -968,15 +977,15 @@ func (e *EndpointService) fetchOpenAIModels(apiUrl, apiKey string) ([]string, er
     return models, nil
 }
 
-func (e *EndpointService) fetchGeminiModels(apiUrl, apiKey string) ([]string, error) {
-    url := fmt.Sprintf("%s/v1beta/models?key=%s", apiUrl, apiKey)
+func (e *EndpointService) fetchGeminiModels(endpoint config.Endpoint, apiUrl string) ([]string, error) {
+    url := fmt.Sprintf("%s/v1beta/models?key=%s", apiUrl, endpoint.APIKey)
 
     req, err := http.NewRequest("GET", url, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to create request: %v", err)
     }
 
-    client := e.createHTTPClient(30 * time.Second)
+    client := e.createHTTPClient(endpoint, 30 * time.Second)
     resp, err := client.Do(req)
     if err != nil {
         return nil, fmt.Errorf("request failed: %v", err)
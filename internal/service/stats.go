@@ -24,6 +24,7 @@ func (s *StatsService) GetStats() string {
 	totalRequests, endpointStats := s.proxy.GetStats().GetStats()
 	data, _ := json.Marshal(map[string]interface{}{
 		"totalRequests": totalRequests,
+		"hedgedRequests": s.proxy.GetStats().GetHedgedRequests(),
 		"endpoints":     endpointStats,
 	})
 	return string(data)
@@ -22,14 +22,51 @@ func (a *ConfigStorageAdapter) GetEndpoints() ([]config.StorageEndpoint, error)
 	result := make([]config.StorageEndpoint, len(endpoints))
 	for i, ep := range endpoints {
 		result[i] = config.StorageEndpoint{
-			Name:        ep.Name,
-			APIUrl:      ep.APIUrl,
-			APIKey:      ep.APIKey,
-			Enabled:     ep.Enabled,
-			Transformer: ep.Transformer,
-			Model:       ep.Model,
-			Remark:      ep.Remark,
-			SortOrder:   ep.SortOrder,
+			Name:             ep.Name,
+			APIUrl:           ep.APIUrl,
+			APIKey:           ep.APIKey,
+			Enabled:          ep.Enabled,
+			Transformer:      ep.Transformer,
+			Model:            ep.Model,
+			Remark:           ep.Remark,
+			SortOrder:        ep.SortOrder,
+			Weight:           ep.Weight,
+			Group:            ep.Group,
+			ModelMap:         ep.ModelMap,
+			MaxConcurrent:    ep.MaxConcurrent,
+			RPM:              ep.RPM,
+			TPM:              ep.TPM,
+			ProxyURL:         ep.ProxyURL,
+			ConnectTimeout:   ep.ConnectTimeout,
+			FirstByteTimeout: ep.FirstByteTimeout,
+			TotalTimeout:     ep.TotalTimeout,
+			AuthStyle:        ep.AuthStyle,
+			AuthHeader:       ep.AuthHeader,
+			Headers:          ep.Headers,
+			APIKeys:          ep.APIKeys,
+			KeyStrategy:      ep.KeyStrategy,
+		}
+	}
+	return result, nil
+}
+
+// GetAccessTokens returns the issued access tokens in config format
+func (a *ConfigStorageAdapter) GetAccessTokens() ([]config.AccessToken, error) {
+	tokens, err := a.storage.GetAccessTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]config.AccessToken, len(tokens))
+	for i, t := range tokens {
+		result[i] = config.AccessToken{
+			ID:        t.ID,
+			Name:      t.Name,
+			TokenHash: t.TokenHash,
+			Endpoints: t.Endpoints,
+			ExpiresAt: t.ExpiresAt,
+			Revoked:   t.RevokedAt != nil,
+			Quota:     t.Quota,
 		}
 	}
 	return result, nil
@@ -38,14 +75,29 @@ func (a *ConfigStorageAdapter) GetEndpoints() ([]config.StorageEndpoint, error)
 // SaveEndpoint saves an endpoint
 func (a *ConfigStorageAdapter) SaveEndpoint(ep *config.StorageEndpoint) error {
 	endpoint := &Endpoint{
-		Name:        ep.Name,
-		APIUrl:      ep.APIUrl,
-		APIKey:      ep.APIKey,
-		Enabled:     ep.Enabled,
-		Transformer: ep.Transformer,
-		Model:       ep.Model,
-		Remark:      ep.Remark,
-		SortOrder:   ep.SortOrder,
+		Name:             ep.Name,
+		APIUrl:           ep.APIUrl,
+		APIKey:           ep.APIKey,
+		Enabled:          ep.Enabled,
+		Transformer:      ep.Transformer,
+		Model:            ep.Model,
+		Remark:           ep.Remark,
+		SortOrder:        ep.SortOrder,
+		Weight:           ep.Weight,
+		Group:            ep.Group,
+		ModelMap:         ep.ModelMap,
+		MaxConcurrent:    ep.MaxConcurrent,
+		RPM:              ep.RPM,
+		TPM:              ep.TPM,
+		ProxyURL:         ep.ProxyURL,
+		ConnectTimeout:   ep.ConnectTimeout,
+		FirstByteTimeout: ep.FirstByteTimeout,
+		TotalTimeout:     ep.TotalTimeout,
+		AuthStyle:        ep.AuthStyle,
+		AuthHeader:       ep.AuthHeader,
+		Headers:          ep.Headers,
+		APIKeys:          ep.APIKeys,
+		KeyStrategy:      ep.KeyStrategy,
 	}
 	return a.storage.SaveEndpoint(endpoint)
 }
@@ -53,14 +105,29 @@ func (a *ConfigStorageAdapter) SaveEndpoint(ep *config.StorageEndpoint) error {
 // UpdateEndpoint updates an endpoint
 func (a *ConfigStorageAdapter) UpdateEndpoint(ep *config.StorageEndpoint) error {
 	endpoint := &Endpoint{
-		Name:        ep.Name,
-		APIUrl:      ep.APIUrl,
-		APIKey:      ep.APIKey,
-		Enabled:     ep.Enabled,
-		Transformer: ep.Transformer,
-		Model:       ep.Model,
-		Remark:      ep.Remark,
-		SortOrder:   ep.SortOrder,
+		Name:             ep.Name,
+		APIUrl:           ep.APIUrl,
+		APIKey:           ep.APIKey,
+		Enabled:          ep.Enabled,
+		Transformer:      ep.Transformer,
+		Model:            ep.Model,
+		Remark:           ep.Remark,
+		SortOrder:        ep.SortOrder,
+		Weight:           ep.Weight,
+		Group:            ep.Group,
+		ModelMap:         ep.ModelMap,
+		MaxConcurrent:    ep.MaxConcurrent,
+		RPM:              ep.RPM,
+		TPM:              ep.TPM,
+		ProxyURL:         ep.ProxyURL,
+		ConnectTimeout:   ep.ConnectTimeout,
+		FirstByteTimeout: ep.FirstByteTimeout,
+		TotalTimeout:     ep.TotalTimeout,
+		AuthStyle:        ep.AuthStyle,
+		AuthHeader:       ep.AuthHeader,
+		Headers:          ep.Headers,
+		APIKeys:          ep.APIKeys,
+		KeyStrategy:      ep.KeyStrategy,
 	}
 	return a.storage.UpdateEndpoint(endpoint)
 }
@@ -1,19 +1,108 @@
 package storage
 
-import "time"
+import (
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
 
 type Endpoint struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	APIUrl      string    `json:"apiUrl"`
-	APIKey      string    `json:"apiKey"`
-	Enabled     bool      `json:"enabled"`
-	Transformer string    `json:"transformer"`
-	Model       string    `json:"model"`
-	Remark      string    `json:"remark"`
-	SortOrder   int       `json:"sortOrder"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID               int64                 `json:"id"`
+	Name             string                `json:"name"`
+	APIUrl           string                `json:"apiUrl"`
+	APIKey           string                `json:"apiKey"`
+	Enabled          bool                  `json:"enabled"`
+	Transformer      string                `json:"transformer"`
+	Model            string                `json:"model"`
+	Remark           string                `json:"remark"`
+	SortOrder        int                   `json:"sortOrder"`
+	Weight           int                   `json:"weight"`
+	Group            string                `json:"group"`
+	ModelMap         []config.ModelMapping `json:"modelMap"`
+	MaxConcurrent    int                   `json:"maxConcurrent"`
+	RPM              int                   `json:"rpm"`
+	TPM              int                   `json:"tpm"`
+	ProxyURL         string                `json:"proxyUrl"`
+	ConnectTimeout   int                   `json:"connectTimeout"`
+	FirstByteTimeout int                   `json:"firstByteTimeout"`
+	TotalTimeout     int                   `json:"totalTimeout"`
+	AuthStyle        string                `json:"authStyle"`
+	AuthHeader       string                `json:"authHeader"`
+	Headers          []config.HeaderRule   `json:"headers"`
+	APIKeys          []config.APIKey       `json:"apiKeys"`
+	KeyStrategy      string                `json:"keyStrategy"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
+}
+
+// AccessToken is a token issued to a client of the proxy
+type AccessToken struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	TokenHash string             `json:"-"`
+	TokenHint string             `json:"tokenHint"` // Last 4 characters, to tell tokens apart
+	Endpoints []string           `json:"endpoints"`
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time         `json:"revokedAt,omitempty"`
+	Quota     config.ClientQuota `json:"quota"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// ClientStat is the usage of one client (access token name) on one day
+type ClientStat struct {
+	ClientName   string
+	Date         string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+	DeviceID     string
+}
+
+// KeyStat is the usage of one API key of an endpoint on one day. The key is only stored
+// as a hash.
+type KeyStat struct {
+	EndpointName string
+	KeyHash      string
+	Date         string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+	DeviceID     string
+}
+
+// RequestLog is one proxied request in the request log
+type RequestLog struct {
+	ID           int64     `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	RequestID    string    `json:"requestId"`
+	Endpoint     string    `json:"endpoint"` // Endpoint of the last attempt, empty if none was tried
+	Model        string    `json:"model"`
+	ClientFormat string    `json:"clientFormat"`
+	Client       string    `json:"client"` // Access token name
+	Status       int       `json:"status"` // Status returned to the client, 0 if the client went away first
+	Attempts     int       `json:"attempts"`
+	LatencyMs    int64     `json:"latencyMs"`
+	TTFTMs       int64     `json:"ttftMs"` // Time to the first streamed event, 0 for non-streaming requests
+	InputTokens  int       `json:"inputTokens"`
+	OutputTokens int       `json:"outputTokens"`
+	Streaming    bool      `json:"streaming"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// RequestLogFilter selects request log rows; zero values match everything
+type RequestLogFilter struct {
+	RequestID  string
+	Endpoint   string
+	Model      string
+	Client     string
+	Status     int
+	ErrorsOnly bool // Only failed requests: status 400 and above, or no response
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
 }
 
 type DailyStat struct {
@@ -48,11 +137,29 @@ type Storage interface {
 	GetAllStats() (map[string][]DailyStat, error)
 	GetTotalStats() (int, map[string]*EndpointStats, error)
 	GetEndpointTotalStats(endpointName string) (*EndpointStats, error)
+	RecordKeyStat(stat *KeyStat) error
+	GetKeyStats() ([]KeyStat, error)
+
+	// Access tokens
+	GetAccessTokens() ([]AccessToken, error)
+	SaveAccessToken(token *AccessToken) error
+	UpdateAccessToken(token *AccessToken) error
+	RevokeAccessToken(id int64) error
+	RecordClientStat(stat *ClientStat) error
+	GetClientStats(startDate, endDate string) ([]ClientStat, error)
+
+	// Request log
+	InsertRequestLogs(logs []RequestLog) error
+	QueryRequestLogs(filter RequestLogFilter) ([]RequestLog, int, error)
+	PruneRequestLogs(before time.Time) (int64, error)
 
 	// Config
 	GetConfig(key string) (string, error)
 	SetConfig(key, value string) error
 
+	// Health
+	Ping() error
+
 	// Close
 	Close() error
 }
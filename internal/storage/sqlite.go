@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/lich0821/ccNexus/internal/config"
 	_ "modernc.org/sqlite"
 )
 
@@ -68,9 +72,68 @@ func (s *SQLiteStorage) initSchema() error {
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS access_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		token_hint TEXT DEFAULT '',
+		endpoints TEXT DEFAULT '',
+		expires_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS client_stats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_name TEXT NOT NULL,
+		date TEXT NOT NULL,
+		requests INTEGER DEFAULT 0,
+		errors INTEGER DEFAULT 0,
+		input_tokens INTEGER DEFAULT 0,
+		output_tokens INTEGER DEFAULT 0,
+		device_id TEXT DEFAULT 'default',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(client_name, date, device_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS key_stats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		endpoint_name TEXT NOT NULL,
+		key_hash TEXT NOT NULL,
+		date TEXT NOT NULL,
+		requests INTEGER DEFAULT 0,
+		errors INTEGER DEFAULT 0,
+		input_tokens INTEGER DEFAULT 0,
+		output_tokens INTEGER DEFAULT 0,
+		device_id TEXT DEFAULT 'default',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(endpoint_name, key_hash, date, device_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS request_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		request_id TEXT DEFAULT '',
+		endpoint_name TEXT DEFAULT '',
+		model TEXT DEFAULT '',
+		client_format TEXT DEFAULT '',
+		client_name TEXT DEFAULT '',
+		status INTEGER DEFAULT 0,
+		attempts INTEGER DEFAULT 0,
+		latency_ms INTEGER DEFAULT 0,
+		ttft_ms INTEGER DEFAULT 0,
+		input_tokens INTEGER DEFAULT 0,
+		output_tokens INTEGER DEFAULT 0,
+		streaming BOOLEAN DEFAULT FALSE,
+		error TEXT DEFAULT ''
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_daily_stats_date ON daily_stats(date);
 	CREATE INDEX IF NOT EXISTS idx_daily_stats_endpoint ON daily_stats(endpoint_name);
 	CREATE INDEX IF NOT EXISTS idx_daily_stats_device ON daily_stats(device_id);
+	CREATE INDEX IF NOT EXISTS idx_client_stats_date ON client_stats(date);
+	CREATE INDEX IF NOT EXISTS idx_request_log_timestamp ON request_log(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_request_log_request_id ON request_log(request_id);
 	`
 
 	if _, err := s.db.Exec(schema); err != nil {
@@ -82,9 +145,83 @@ func (s *SQLiteStorage) initSchema() error {
 		return err
 	}
 
+	// Migration: Add weight column for weighted load balancing
+	if err := s.addColumnIfMissing("endpoints", "weight", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Migration: Add endpoint_group column for model routing
+	if err := s.addColumnIfMissing("endpoints", "endpoint_group", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Migration: Add model_map column (JSON) for per-endpoint model mapping
+	if err := s.addColumnIfMissing("endpoints", "model_map", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Migration: Add max_concurrent column for per-endpoint concurrency limits
+	if err := s.addColumnIfMissing("endpoints", "max_concurrent", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Migration: Add rpm/tpm columns for client-side rate limits
+	if err := s.addColumnIfMissing("endpoints", "rpm", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("endpoints", "tpm", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Migration: Add per-endpoint proxy and timeout columns
+	if err := s.addColumnIfMissing("endpoints", "proxy_url", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	for _, col := range []string{"connect_timeout", "first_byte_timeout", "total_timeout"} {
+		if err := s.addColumnIfMissing("endpoints", col, "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+
+	// Migration: Add auth style and header rule columns
+	for _, col := range []string{"auth_style", "auth_header", "header_rules"} {
+		if err := s.addColumnIfMissing("endpoints", col, "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+
+	// Migration: Add additional API key columns
+	for _, col := range []string{"api_keys", "key_strategy"} {
+		if err := s.addColumnIfMissing("endpoints", col, "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+
+	// Migration: Add client quota columns to access_tokens
+	for _, col := range []string{"daily_requests", "daily_tokens", "monthly_requests", "monthly_tokens"} {
+		if err := s.addColumnIfMissing("access_tokens", col, "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table when it is not present yet
+func (s *SQLiteStorage) addColumnIfMissing(table, column, definition string) error {
+	var count int
+	err := s.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM pragma_table_info('%s') WHERE name=?`, table), column).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
 // migrateSortOrder adds the sort_order column to existing databases
 func (s *SQLiteStorage) migrateSortOrder() error {
 	// Check if sort_order column exists
@@ -114,7 +251,7 @@ func (s *SQLiteStorage) GetEndpoints() ([]Endpoint, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	rows, err := s.db.Query(`SELECT id, name, api_url, api_key, enabled, transformer, model, remark, sort_order, created_at, updated_at FROM endpoints ORDER BY sort_order ASC`)
+	rows, err := s.db.Query(`SELECT id, name, api_url, api_key, enabled, transformer, model, remark, sort_order, COALESCE(weight, 0), COALESCE(endpoint_group, ''), COALESCE(model_map, ''), COALESCE(max_concurrent, 0), COALESCE(rpm, 0), COALESCE(tpm, 0), COALESCE(proxy_url, ''), COALESCE(connect_timeout, 0), COALESCE(first_byte_timeout, 0), COALESCE(total_timeout, 0), COALESCE(auth_style, ''), COALESCE(auth_header, ''), COALESCE(header_rules, ''), COALESCE(api_keys, ''), COALESCE(key_strategy, ''), created_at, updated_at FROM endpoints ORDER BY sort_order ASC`)
 	if err != nil {
 		return nil, err
 	}
@@ -123,9 +260,13 @@ func (s *SQLiteStorage) GetEndpoints() ([]Endpoint, error) {
 	var endpoints []Endpoint
 	for rows.Next() {
 		var ep Endpoint
-		if err := rows.Scan(&ep.ID, &ep.Name, &ep.APIUrl, &ep.APIKey, &ep.Enabled, &ep.Transformer, &ep.Model, &ep.Remark, &ep.SortOrder, &ep.CreatedAt, &ep.UpdatedAt); err != nil {
+		var modelMap, headerRules, apiKeys string
+		if err := rows.Scan(&ep.ID, &ep.Name, &ep.APIUrl, &ep.APIKey, &ep.Enabled, &ep.Transformer, &ep.Model, &ep.Remark, &ep.SortOrder, &ep.Weight, &ep.Group, &modelMap, &ep.MaxConcurrent, &ep.RPM, &ep.TPM, &ep.ProxyURL, &ep.ConnectTimeout, &ep.FirstByteTimeout, &ep.TotalTimeout, &ep.AuthStyle, &ep.AuthHeader, &headerRules, &apiKeys, &ep.KeyStrategy, &ep.CreatedAt, &ep.UpdatedAt); err != nil {
 			return nil, err
 		}
+		ep.ModelMap = decodeModelMap(modelMap)
+		ep.Headers = decodeHeaderRules(headerRules)
+		ep.APIKeys = decodeAPIKeys(apiKeys)
 		endpoints = append(endpoints, ep)
 	}
 
@@ -136,8 +277,8 @@ func (s *SQLiteStorage) SaveEndpoint(ep *Endpoint) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	result, err := s.db.Exec(`INSERT INTO endpoints (name, api_url, api_key, enabled, transformer, model, remark, sort_order) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		ep.Name, ep.APIUrl, ep.APIKey, ep.Enabled, ep.Transformer, ep.Model, ep.Remark, ep.SortOrder)
+	result, err := s.db.Exec(`INSERT INTO endpoints (name, api_url, api_key, enabled, transformer, model, remark, sort_order, weight, endpoint_group, model_map, max_concurrent, rpm, tpm, proxy_url, connect_timeout, first_byte_timeout, total_timeout, auth_style, auth_header, header_rules, api_keys, key_strategy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ep.Name, ep.APIUrl, ep.APIKey, ep.Enabled, ep.Transformer, ep.Model, ep.Remark, ep.SortOrder, ep.Weight, ep.Group, encodeModelMap(ep.ModelMap), ep.MaxConcurrent, ep.RPM, ep.TPM, ep.ProxyURL, ep.ConnectTimeout, ep.FirstByteTimeout, ep.TotalTimeout, ep.AuthStyle, ep.AuthHeader, encodeHeaderRules(ep.Headers), encodeAPIKeys(ep.APIKeys), ep.KeyStrategy)
 	if err != nil {
 		return err
 	}
@@ -154,11 +295,83 @@ func (s *SQLiteStorage) UpdateEndpoint(ep *Endpoint) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.Exec(`UPDATE endpoints SET api_url=?, api_key=?, enabled=?, transformer=?, model=?, remark=?, sort_order=?, updated_at=CURRENT_TIMESTAMP WHERE name=?`,
-		ep.APIUrl, ep.APIKey, ep.Enabled, ep.Transformer, ep.Model, ep.Remark, ep.SortOrder, ep.Name)
+	_, err := s.db.Exec(`UPDATE endpoints SET api_url=?, api_key=?, enabled=?, transformer=?, model=?, remark=?, sort_order=?, weight=?, endpoint_group=?, model_map=?, max_concurrent=?, rpm=?, tpm=?, proxy_url=?, connect_timeout=?, first_byte_timeout=?, total_timeout=?, auth_style=?, auth_header=?, header_rules=?, api_keys=?, key_strategy=?, updated_at=CURRENT_TIMESTAMP WHERE name=?`,
+		ep.APIUrl, ep.APIKey, ep.Enabled, ep.Transformer, ep.Model, ep.Remark, ep.SortOrder, ep.Weight, ep.Group, encodeModelMap(ep.ModelMap), ep.MaxConcurrent, ep.RPM, ep.TPM, ep.ProxyURL, ep.ConnectTimeout, ep.FirstByteTimeout, ep.TotalTimeout, ep.AuthStyle, ep.AuthHeader, encodeHeaderRules(ep.Headers), encodeAPIKeys(ep.APIKeys), ep.KeyStrategy, ep.Name)
 	return err
 }
 
+// encodeModelMap serializes a model mapping table for the model_map column
+func encodeModelMap(mappings []config.ModelMapping) string {
+	if len(mappings) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(mappings)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeModelMap parses the model_map column, ignoring malformed values
+func decodeModelMap(value string) []config.ModelMapping {
+	if value == "" {
+		return nil
+	}
+	var mappings []config.ModelMapping
+	if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+		return nil
+	}
+	return mappings
+}
+
+// encodeHeaderRules serializes header rules for the header_rules column
+func encodeHeaderRules(rules []config.HeaderRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeHeaderRules parses the header_rules column, ignoring malformed values
+func decodeHeaderRules(value string) []config.HeaderRule {
+	if value == "" {
+		return nil
+	}
+	var rules []config.HeaderRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil
+	}
+	return rules
+}
+
+// encodeAPIKeys serializes additional API keys for the api_keys column
+func encodeAPIKeys(keys []config.APIKey) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeAPIKeys parses the api_keys column, ignoring malformed values
+func decodeAPIKeys(value string) []config.APIKey {
+	if value == "" {
+		return nil
+	}
+	var keys []config.APIKey
+	if err := json.Unmarshal([]byte(value), &keys); err != nil {
+		return nil
+	}
+	return keys
+}
+
 func (s *SQLiteStorage) DeleteEndpoint(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -167,6 +380,96 @@ func (s *SQLiteStorage) DeleteEndpoint(name string) error {
 	return err
 }
 
+func (s *SQLiteStorage) GetAccessTokens() ([]AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT id, name, token_hash, COALESCE(token_hint, ''), COALESCE(endpoints, ''), expires_at, revoked_at, COALESCE(daily_requests, 0), COALESCE(daily_tokens, 0), COALESCE(monthly_requests, 0), COALESCE(monthly_tokens, 0), created_at FROM access_tokens ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AccessToken
+	for rows.Next() {
+		var t AccessToken
+		var endpoints string
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.TokenHash, &t.TokenHint, &endpoints, &expiresAt, &revokedAt, &t.Quota.DailyRequests, &t.Quota.DailyTokens, &t.Quota.MonthlyRequests, &t.Quota.MonthlyTokens, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if endpoints != "" {
+			json.Unmarshal([]byte(endpoints), &t.Endpoints)
+		}
+		if expiresAt.Valid {
+			t.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			t.RevokedAt = &revokedAt.Time
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (s *SQLiteStorage) SaveAccessToken(t *AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.CreatedAt = time.Now()
+	result, err := s.db.Exec(`INSERT INTO access_tokens (name, token_hash, token_hint, endpoints, expires_at, daily_requests, daily_tokens, monthly_requests, monthly_tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.Name, t.TokenHash, t.TokenHint, encodeTokenEndpoints(t.Endpoints), t.ExpiresAt, t.Quota.DailyRequests, t.Quota.DailyTokens, t.Quota.MonthlyRequests, t.Quota.MonthlyTokens, t.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.ID = id
+	return nil
+}
+
+// UpdateAccessToken updates the endpoints, expiry and quota of a token
+func (s *SQLiteStorage) UpdateAccessToken(t *AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`UPDATE access_tokens SET endpoints=?, expires_at=?, daily_requests=?, daily_tokens=?, monthly_requests=?, monthly_tokens=? WHERE id=?`,
+		encodeTokenEndpoints(t.Endpoints), t.ExpiresAt, t.Quota.DailyRequests, t.Quota.DailyTokens, t.Quota.MonthlyRequests, t.Quota.MonthlyTokens, t.ID)
+	return err
+}
+
+// encodeTokenEndpoints serializes the endpoint list of a token for the endpoints column
+func encodeTokenEndpoints(endpoints []string) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(endpoints)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// RevokeAccessToken marks a token revoked. The row is kept so that a revoked token keeps
+// its name and clients cannot reuse it.
+func (s *SQLiteStorage) RevokeAccessToken(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`UPDATE access_tokens SET revoked_at=? WHERE id=? AND revoked_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (s *SQLiteStorage) RecordDailyStat(stat *DailyStat) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -184,6 +487,201 @@ func (s *SQLiteStorage) RecordDailyStat(stat *DailyStat) error {
 	return err
 }
 
+func (s *SQLiteStorage) RecordClientStat(stat *ClientStat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO client_stats (client_name, date, requests, errors, input_tokens, output_tokens, device_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(client_name, date, device_id) DO UPDATE SET
+			requests = requests + excluded.requests,
+			errors = errors + excluded.errors,
+			input_tokens = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens
+	`, stat.ClientName, stat.Date, stat.Requests, stat.Errors, stat.InputTokens, stat.OutputTokens, stat.DeviceID)
+
+	return err
+}
+
+// GetClientStats returns the usage per client and day in a date range, summed over devices
+func (s *SQLiteStorage) GetClientStats(startDate, endDate string) ([]ClientStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT client_name, date, SUM(requests), SUM(errors), SUM(input_tokens), SUM(output_tokens)
+		FROM client_stats WHERE date>=? AND date<=? GROUP BY client_name, date ORDER BY date DESC`, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []ClientStat
+	for rows.Next() {
+		var stat ClientStat
+		if err := rows.Scan(&stat.ClientName, &stat.Date, &stat.Requests, &stat.Errors, &stat.InputTokens, &stat.OutputTokens); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *SQLiteStorage) RecordKeyStat(stat *KeyStat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.Exec(`
+		INSERT INTO key_stats (endpoint_name, key_hash, date, requests, errors, input_tokens, output_tokens, device_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(endpoint_name, key_hash, date, device_id) DO UPDATE SET
+			requests = requests + excluded.requests,
+			errors = errors + excluded.errors,
+			input_tokens = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens
+	`, stat.EndpointName, stat.KeyHash, stat.Date, stat.Requests, stat.Errors, stat.InputTokens, stat.OutputTokens, stat.DeviceID)
+
+	return err
+}
+
+// GetKeyStats returns the total usage per endpoint and API key, summed over days and devices
+func (s *SQLiteStorage) GetKeyStats() ([]KeyStat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows, err := s.db.Query(`SELECT endpoint_name, key_hash, SUM(requests), SUM(errors), SUM(input_tokens), SUM(output_tokens)
+		FROM key_stats GROUP BY endpoint_name, key_hash`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []KeyStat
+	for rows.Next() {
+		var stat KeyStat
+		if err := rows.Scan(&stat.EndpointName, &stat.KeyHash, &stat.Requests, &stat.Errors, &stat.InputTokens, &stat.OutputTokens); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// InsertRequestLogs writes a batch of request log rows in one transaction
+func (s *SQLiteStorage) InsertRequestLogs(logs []RequestLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO request_log (timestamp, request_id, endpoint_name, model, client_format, client_name,
+		status, attempts, latency_ms, ttft_ms, input_tokens, output_tokens, streaming, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, l := range logs {
+		if _, err := stmt.Exec(l.Timestamp.UnixMilli(), l.RequestID, l.Endpoint, l.Model, l.ClientFormat, l.Client,
+			l.Status, l.Attempts, l.LatencyMs, l.TTFTMs, l.InputTokens, l.OutputTokens, l.Streaming, l.Error); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryRequestLogs returns the request log rows matching a filter, newest first, and the
+// number of matching rows without the limit
+func (s *SQLiteStorage) QueryRequestLogs(filter RequestLogFilter) ([]RequestLog, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	where := []string{"1=1"}
+	var args []interface{}
+	if filter.RequestID != "" {
+		where = append(where, "request_id=?")
+		args = append(args, filter.RequestID)
+	}
+	if filter.Endpoint != "" {
+		where = append(where, "endpoint_name=?")
+		args = append(args, filter.Endpoint)
+	}
+	if filter.Model != "" {
+		where = append(where, "model=?")
+		args = append(args, filter.Model)
+	}
+	if filter.Client != "" {
+		where = append(where, "client_name=?")
+		args = append(args, filter.Client)
+	}
+	if filter.Status != 0 {
+		where = append(where, "status=?")
+		args = append(args, filter.Status)
+	}
+	if filter.ErrorsOnly {
+		where = append(where, "(status>=400 OR status=0)")
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "timestamp>=?")
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "timestamp<?")
+		args = append(args, filter.To.UnixMilli())
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM request_log WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, timestamp, request_id, endpoint_name, model, client_format, client_name,
+		status, attempts, latency_ms, ttft_ms, input_tokens, output_tokens, streaming, error
+		FROM request_log WHERE `+cond+` ORDER BY timestamp DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []RequestLog{}
+	for rows.Next() {
+		var l RequestLog
+		var ts int64
+		if err := rows.Scan(&l.ID, &ts, &l.RequestID, &l.Endpoint, &l.Model, &l.ClientFormat, &l.Client,
+			&l.Status, &l.Attempts, &l.LatencyMs, &l.TTFTMs, &l.InputTokens, &l.OutputTokens, &l.Streaming, &l.Error); err != nil {
+			return nil, 0, err
+		}
+		l.Timestamp = time.UnixMilli(ts)
+		logs = append(logs, l)
+	}
+	return logs, total, rows.Err()
+}
+
+// PruneRequestLogs deletes the request log rows older than before and returns how many were removed
+func (s *SQLiteStorage) PruneRequestLogs(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.Exec(`DELETE FROM request_log WHERE timestamp<?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (s *SQLiteStorage) GetDailyStats(endpointName, startDate, endDate string) ([]DailyStat, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -256,6 +754,15 @@ func (s *SQLiteStorage) Close() error {
 	return s.db.Close()
 }
 
+// Ping checks that the database can still answer a query
+func (s *SQLiteStorage) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var one int
+	return s.db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
 func (s *SQLiteStorage) GetTotalStats() (int, map[string]*EndpointStats, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
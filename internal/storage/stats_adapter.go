@@ -1,6 +1,9 @@
 package storage
 
-import "reflect"
+import (
+	"reflect"
+	"time"
+)
 
 // StatsStorageAdapter adapts SQLiteStorage to be used by proxy.Stats
 // It implements the proxy.StatsStorage interface
@@ -90,3 +93,145 @@ type DailyRecordCompat struct {
 	InputTokens  int
 	OutputTokens int
 }
+
+// RecordClientStat records the daily usage of a client
+func (a *StatsStorageAdapter) RecordClientStat(stat interface{}) error {
+	v := reflect.ValueOf(stat)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	clientStat := &ClientStat{
+		ClientName:   v.FieldByName("ClientName").String(),
+		Date:         v.FieldByName("Date").String(),
+		Requests:     int(v.FieldByName("Requests").Int()),
+		Errors:       int(v.FieldByName("Errors").Int()),
+		InputTokens:  int(v.FieldByName("InputTokens").Int()),
+		OutputTokens: int(v.FieldByName("OutputTokens").Int()),
+		DeviceID:     v.FieldByName("DeviceID").String(),
+	}
+	return a.storage.RecordClientStat(clientStat)
+}
+
+// RecordKeyStat records the daily usage of an API key
+func (a *StatsStorageAdapter) RecordKeyStat(stat interface{}) error {
+	v := reflect.ValueOf(stat)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	keyStat := &KeyStat{
+		EndpointName: v.FieldByName("EndpointName").String(),
+		KeyHash:      v.FieldByName("KeyHash").String(),
+		Date:         v.FieldByName("Date").String(),
+		Requests:     int(v.FieldByName("Requests").Int()),
+		Errors:       int(v.FieldByName("Errors").Int()),
+		InputTokens:  int(v.FieldByName("InputTokens").Int()),
+		OutputTokens: int(v.FieldByName("OutputTokens").Int()),
+		DeviceID:     v.FieldByName("DeviceID").String(),
+	}
+	return a.storage.RecordKeyStat(keyStat)
+}
+
+// GetKeyStats gets the total usage per endpoint and API key
+func (a *StatsStorageAdapter) GetKeyStats() ([]interface{}, error) {
+	keyStats, err := a.storage.GetKeyStats()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, len(keyStats))
+	for i, stat := range keyStats {
+		result[i] = &KeyRecordCompat{
+			EndpointName: stat.EndpointName,
+			KeyHash:      stat.KeyHash,
+			Requests:     stat.Requests,
+			Errors:       stat.Errors,
+			InputTokens:  stat.InputTokens,
+			OutputTokens: stat.OutputTokens,
+		}
+	}
+
+	return result, nil
+}
+
+// KeyRecordCompat is a compatible API key usage record structure
+type KeyRecordCompat struct {
+	EndpointName string
+	KeyHash      string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+}
+
+// Ping checks that the database is reachable
+func (a *StatsStorageAdapter) Ping() error {
+	return a.storage.Ping()
+}
+
+// GetClientStats gets the usage per client and day in a date range
+func (a *StatsStorageAdapter) GetClientStats(startDate, endDate string) ([]interface{}, error) {
+	clientStats, err := a.storage.GetClientStats(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, len(clientStats))
+	for i, stat := range clientStats {
+		result[i] = &ClientRecordCompat{
+			ClientName:   stat.ClientName,
+			Date:         stat.Date,
+			Requests:     stat.Requests,
+			Errors:       stat.Errors,
+			InputTokens:  stat.InputTokens,
+			OutputTokens: stat.OutputTokens,
+		}
+	}
+
+	return result, nil
+}
+
+// ClientRecordCompat is a compatible client usage record structure
+type ClientRecordCompat struct {
+	ClientName   string
+	Date         string
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+}
+
+// RecordRequestLogs writes a batch of request log records
+func (a *StatsStorageAdapter) RecordRequestLogs(records interface{}) error {
+	v := reflect.ValueOf(records)
+	logs := make([]RequestLog, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		r := v.Index(i)
+		if r.Kind() == reflect.Ptr {
+			r = r.Elem()
+		}
+		logs = append(logs, RequestLog{
+			Timestamp:    r.FieldByName("Timestamp").Interface().(time.Time),
+			RequestID:    r.FieldByName("RequestID").String(),
+			Endpoint:     r.FieldByName("Endpoint").String(),
+			Model:        r.FieldByName("Model").String(),
+			ClientFormat: r.FieldByName("ClientFormat").String(),
+			Client:       r.FieldByName("Client").String(),
+			Status:       int(r.FieldByName("Status").Int()),
+			Attempts:     int(r.FieldByName("Attempts").Int()),
+			LatencyMs:    r.FieldByName("LatencyMs").Int(),
+			TTFTMs:       r.FieldByName("TTFTMs").Int(),
+			InputTokens:  int(r.FieldByName("InputTokens").Int()),
+			OutputTokens: int(r.FieldByName("OutputTokens").Int()),
+			Streaming:    r.FieldByName("Streaming").Bool(),
+			Error:        r.FieldByName("Error").String(),
+		})
+	}
+	return a.storage.InsertRequestLogs(logs)
+}
+
+// PruneRequestLogs deletes request log records older than before
+func (a *StatsStorageAdapter) PruneRequestLogs(before time.Time) (int64, error) {
+	return a.storage.PruneRequestLogs(before)
+}
@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+	"github.com/lich0821/ccNexus/internal/logger"
+)
+
+const (
+	// queueSize is the number of ended spans waiting for export; more are dropped
+	queueSize = 2048
+	// batchSize is the largest number of spans sent in one export request
+	batchSize = 256
+	// flushInterval is the longest time an ended span waits for export
+	flushInterval = 5 * time.Second
+	// exportTimeout bounds one export request to the collector
+	exportTimeout = 10 * time.Second
+	// scopeName is the instrumentation scope of the exported spans
+	scopeName = "github.com/lich0821/ccNexus/internal/proxy"
+)
+
+// exporter batches ended spans and posts them to an OTLP/HTTP collector in the background
+type exporter struct {
+	cfg    config.TracingConfig
+	client *http.Client
+	queue  chan *Span
+	stop   chan struct{}
+	done   chan struct{}
+
+	stopOnce sync.Once
+	failing  bool // last export failed; only the first failure in a row is logged
+}
+
+// newExporter starts an exporter for the given settings
+func newExporter(cfg config.TracingConfig) *exporter {
+	e := &exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan *Span, queueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	logger.Info("[TRACING] Exporting traces to %s", cfg.Endpoint)
+	return e
+}
+
+// enqueue queues an ended span, dropping it if the queue is full or the exporter stopped
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case <-e.stop:
+		return
+	default:
+	}
+	select {
+	case e.queue <- span:
+	default:
+		logger.Debug("[TRACING] Export queue full, dropped span %s", span.name)
+	}
+}
+
+// shutdown stops the exporter after sending the spans still queued
+func (e *exporter) shutdown() {
+	e.stopOnce.Do(func() { close(e.stop) })
+	<-e.done
+}
+
+// run sends the queued spans whenever a batch is full or the flush interval passed
+func (e *exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= batchSize {
+						e.export(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						e.export(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts a batch of spans to the collector
+func (e *exporter) export(batch []*Span) {
+	body, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		logger.Error("[TRACING] Failed to encode spans: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		e.exportFailed(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.exportFailed(err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		e.exportFailed(fmt.Errorf("collector returned HTTP %d", resp.StatusCode))
+		return
+	}
+	if e.failing {
+		logger.Info("[TRACING] Trace export recovered")
+		e.failing = false
+	}
+}
+
+// exportFailed logs the first of a series of failed exports; the batch is dropped
+func (e *exporter) exportFailed(err error) {
+	if !e.failing {
+		logger.Warn("[TRACING] Failed to export spans to %s: %v", e.cfg.Endpoint, err)
+		e.failing = true
+	}
+}
+
+// encode builds the OTLP JSON request for a batch of spans
+func (e *exporter) encode(batch []*Span) map[string]interface{} {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.encode())
+	}
+
+	serviceName := e.cfg.ServiceName
+	if serviceName == "" {
+		serviceName = config.DefaultTracingConfig().ServiceName
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []interface{}{encodeAttribute("service.name", serviceName)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": scopeName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// encode renders an ended span as an OTLP JSON span
+func (s *Span) encode() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := make([]interface{}, 0, len(s.attrs))
+	for _, a := range s.attrs {
+		attrs = append(attrs, encodeAttribute(a.key, a.value))
+	}
+	status := map[string]interface{}{}
+	if s.failed {
+		status["code"] = 2
+		status["message"] = s.statusMsg
+	}
+
+	span := map[string]interface{}{
+		"traceId":           hex.EncodeToString(s.traceID[:]),
+		"spanId":            hex.EncodeToString(s.spanID[:]),
+		"name":              s.name,
+		"kind":              int(s.kind),
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        attrs,
+		"status":            status,
+	}
+	if s.parentID != [8]byte{} {
+		span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+	}
+	return span
+}
+
+// encodeAttribute renders a key/value pair as an OTLP JSON attribute
+func encodeAttribute(key string, value interface{}) map[string]interface{} {
+	var v map[string]interface{}
+	switch val := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": val}
+	case bool:
+		v = map[string]interface{}{"boolValue": val}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": val}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+	return map[string]interface{}{"key": key, "value": v}
+}
@@ -0,0 +1,247 @@
+// Package tracing records OpenTelemetry spans for proxy requests and exports them to an
+// OTLP/HTTP collector in the JSON encoding. Spans are created only while export is enabled;
+// every Span method is a no-op on a nil span, so callers need no checks of their own.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lich0821/ccNexus/internal/config"
+)
+
+// SpanKind is the OTLP kind of a span
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// traceparentHeader carries the W3C trace context
+const traceparentHeader = "traceparent"
+
+// Span is one timed operation of a trace
+type Span struct {
+	exporter *exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu        sync.Mutex
+	end       time.Time
+	attrs     []attribute
+	failed    bool
+	statusMsg string
+	ended     bool
+}
+
+// attribute is a key/value pair of a span
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// SetAttribute sets an attribute on the span. Values are strings, ints, int64s, float64s or bools.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attrs {
+		if s.attrs[i].key == key {
+			s.attrs[i].value = value
+			return
+		}
+	}
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed with the given message
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.statusMsg = msg
+}
+
+// End finishes the span and queues it for export. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.exporter.enqueue(s)
+}
+
+// Inject sets the traceparent header so the next hop continues this trace as a child of the span
+func (s *Span) Inject(header http.Header) {
+	if s == nil {
+		return
+	}
+	header.Set(traceparentHeader, "00-"+hex.EncodeToString(s.traceID[:])+"-"+hex.EncodeToString(s.spanID[:])+"-01")
+}
+
+// TraceID returns the hex trace ID of the span, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// FromContext returns the current span of a context, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan returns a context whose current span is span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Tracer creates spans and exports them according to the current trace export settings
+type Tracer struct {
+	mu       sync.Mutex
+	cfg      config.TracingConfig
+	exporter *exporter
+}
+
+// NewTracer creates a tracer with export disabled
+func NewTracer() *Tracer {
+	return &Tracer{}
+}
+
+// Configure applies the trace export settings, restarting the exporter when they changed.
+// Spans already queued are flushed to the previous collector.
+func (t *Tracer) Configure(cfg config.TracingConfig) {
+	t.mu.Lock()
+	if cfg == t.cfg {
+		t.mu.Unlock()
+		return
+	}
+	old := t.exporter
+	t.cfg = cfg
+	t.exporter = nil
+	if cfg.Enabled {
+		t.exporter = newExporter(cfg)
+	}
+	t.mu.Unlock()
+
+	if old != nil {
+		old.shutdown()
+	}
+}
+
+// Shutdown flushes the queued spans and stops the exporter
+func (t *Tracer) Shutdown() {
+	t.mu.Lock()
+	old := t.exporter
+	t.exporter = nil
+	t.cfg = config.TracingConfig{}
+	t.mu.Unlock()
+
+	if old != nil {
+		old.shutdown()
+	}
+}
+
+// StartRoot starts the server span of an incoming request. A valid traceparent header makes
+// the span part of the caller's trace and decides the sampling; otherwise the sample ratio does.
+// The span is nil when export is disabled or the request is not sampled.
+func (t *Tracer) StartRoot(ctx context.Context, name string, header http.Header) (context.Context, *Span) {
+	t.mu.Lock()
+	exp := t.exporter
+	ratio := t.cfg.SampleRatio
+	t.mu.Unlock()
+	if exp == nil {
+		return ctx, nil
+	}
+
+	span := &Span{exporter: exp, name: name, kind: KindServer, start: time.Now()}
+	if traceID, parentID, sampled, ok := parseTraceparent(header.Get(traceparentHeader)); ok {
+		if !sampled {
+			return ctx, nil
+		}
+		span.traceID = traceID
+		span.parentID = parentID
+	} else {
+		if rand.Float64() >= ratio {
+			return ctx, nil
+		}
+		randomBytes(span.traceID[:])
+	}
+	randomBytes(span.spanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// Start starts a child of the context's current span. Without a current span nothing is traced
+// and the span is nil.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		exporter: parent.exporter,
+		traceID:  parent.traceID,
+		parentID: parent.spanID,
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+	}
+	randomBytes(span.spanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// parseTraceparent reads a W3C traceparent header: version-traceid-parentid-flags
+func parseTraceparent(value string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return traceID, parentID, false, false
+	}
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// randomBytes fills b with random bytes for trace and span IDs
+func randomBytes(b []byte) {
+	crand.Read(b)
+}